@@ -1,39 +1,264 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"sync"
 
-	"github.com/AbdallahZerfaoui/poweq/solver"
+	"github.com/gin-gonic/gin/binding"
 
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/plot"
+	"github.com/AbdallahZerfaoui/poweq/solver"
 )
 
-func (req SolveRequest) Solve4API() (SolveResponse, error) {
-	var resp SolveResponse
-
-	// Call the solver function
-	job := solver.Job{
-		Id:      0, // Or random ID for the job
-		N:       req.N,
-		M:       req.M,
-		K:       req.K,
-		A:       req.A,
-		B:       req.B,
-		Tol:     req.Tolerance,
-		MaxIter: req.MaxIter,
-	}
-	solutions := job.Solve(req.Algorithm, logger)
+// ErrInvalidJob tags the errors of requests rejected before solving.
+var ErrInvalidJob = errors.New("invalid job")
+
+// requestJob builds the job of a request filled with its defaults and
+// checks that it can be solved with its algorithm.
+func requestJob(req solver.SolveRequest) (solver.Job, error) {
+	job, err := req.Job()
+	if err == nil {
+		err = job.Validate()
+	}
+	if err == nil && !solver.IsMethod(req.Algorithm) {
+		err = fmt.Errorf("field \"algorithm\": unknown algorithm %q", req.Algorithm)
+	}
+	if err != nil {
+		return job, fmt.Errorf("%w: %w", ErrInvalidJob, err)
+	}
+	return job, nil
+}
+
+// Solve4API solves a request with the JSON schema shared with the CLI.
+// Roots are rounded to digits significant digits, 0 keeps them exact.
+// A request that is not a valid job fails with ErrInvalidJob.
+func Solve4API(req solver.SolveRequest, digits int) (solver.SolveResponse, error) {
+	var resp solver.SolveResponse
+	req = withDefaults(req)
+
+	// Validated first, the cache only holds the results of valid jobs
+	job, err := requestJob(req)
+	if err != nil {
+		return resp, err
+	}
+	solutions := cache.Solve(job, req.Algorithm)
 	if len(solutions) == 0 {
 		return resp, errors.New("no solutions found")
 	}
 
-	resp.Solutions = make([]APISolution, len(solutions))
-	for i, sol := range solutions {
-		resp.Solutions[i] = APISolution{
-			X:     sol.X,
-			Steps: sol.Steps,
-			Error: sol.Err,
+	resp = solver.NewSolveResponse(job.Id, solutions)
+	resp.Round(digits)
+	return resp, nil
+}
+
+// BatchResponse holds the results of a batch, in the order of the jobs.
+type BatchResponse struct {
+	Results []solver.SolveResponse `json:"results"`
+	Errors  int                    `json:"errors"` // Jobs rejected, with Error set in their result
+}
+
+// Batch4API solves the jobs of a batch request on a bounded pool. A job
+// that cannot be decoded, is invalid or cannot be solved gets an error in
+// its result and does not fail the others. Jobs not started when ctx is
+// done are failed.
+// progress, unless nil, is called as each job completes.
+func Batch4API(ctx context.Context, items []json.RawMessage, digits int, progress func()) BatchResponse {
+	batch := BatchResponse{Results: make([]solver.SolveResponse, len(items))}
+	solveItem := func(i int) {
+		var req solver.SolveRequest
+		err := json.Unmarshal(items[i], &req)
+		decoded := err == nil
+		if err == nil {
+			err = binding.Validator.ValidateStruct(&req)
+		}
+		if err == nil {
+			// Invalid jobs fail in their result without waiting for a slot
+			_, err = requestJob(withDefaults(req))
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		var resp solver.SolveResponse
+		if err == nil {
+			solveSlots <- struct{}{}
+			resp, err = Solve4API(req, digits)
+			<-solveSlots
+		}
+		if err != nil {
+			resp = solver.SolveResponse{Id: req.Id, Solutions: []solver.Solution{}, Error: err.Error()}
+		}
+		if decoded {
+			req = withDefaults(req)
+			resp.Job = &req
+		}
+		batch.Results[i] = resp
+		if progress != nil {
+			progress()
 		}
 	}
 
-	return resp, nil
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(len(items), cap(solveSlots)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				solveItem(i)
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, resp := range batch.Results {
+		if resp.Error != "" {
+			batch.Errors++
+		}
+	}
+	return batch
+}
+
+// withDefaults fills the optional fields a request leaves unset from the
+// configuration.
+func withDefaults(req solver.SolveRequest) solver.SolveRequest {
+	s := conf.Solver
+	if req.Algorithm == "" {
+		req.Algorithm = s.Algorithm
+	}
+	if req.Tolerance == 0 {
+		req.Tolerance = s.Tolerance
+	}
+	if req.MaxIter == 0 {
+		req.MaxIter = s.MaxIter
+	}
+	if req.Precision == 0 {
+		req.Precision = s.Precision
+	}
+	if req.Criterion == "" {
+		req.Criterion = s.Criterion
+	}
+	if req.Timeout == "" && s.Timeout > 0 {
+		req.Timeout = s.Timeout.String()
+	}
+	return req
+}
+
+// SCAN_FLUSH_ROWS is the number of jobs after which the results of an
+// upload are pushed to the client.
+const SCAN_FLUSH_ROWS = 100
+
+// ScanQuery holds the options of the scan endpoint, from the query string.
+// They are those of poweq scan, with the same defaults.
+type ScanQuery struct {
+	Delim         string  `form:"delim"`   // Default ","
+	Comment       *string `form:"comment"` // Default "#", empty to disable
+	StrictColumns bool    `form:"strict-columns"`
+}
+
+// Options returns the options of the jobs file, the missing parameters of
+// its rows taken from the configuration.
+func (query ScanQuery) Options(digits int) (jobfile.Options, error) {
+	s := conf.Solver
+	opts := jobfile.Options{StrictColumns: query.StrictColumns, Digits: digits, Defaults: jobfile.Defaults{
+		Tol: s.Tolerance, MaxIter: s.MaxIter, Algorithm: s.Algorithm,
+		Precision: s.Precision, Criterion: s.Criterion, Timeout: s.Timeout,
+	}}
+	comment := "#"
+	if query.Comment != nil {
+		comment = *query.Comment
+	}
+	var err error
+	if opts.Delimiter, err = jobfile.ParseRune("delim", query.Delim); err != nil {
+		return opts, err
+	}
+	if opts.Comment, err = jobfile.ParseRune("comment", comment); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// Scan4API solves the jobs of an uploaded file as poweq scan does, on a
+// pool of api.workers sharing the solve slots of the other requests, and
+// writes their results in the order of the file as they come. Rejected
+// rows are written as such and do not stop the others. flush is called
+// every SCAN_FLUSH_ROWS jobs, once the results so far are written. Rows
+// are no longer read once ctx is done.
+func Scan4API(ctx context.Context, reader jobfile.Reader, writer jobfile.Writer, flush func()) (jobfile.ScanCounts, error) {
+	var counts jobfile.ScanCounts
+	if err := writer.WriteHeader(); err != nil {
+		return counts, err
+	}
+	scanner := jobfile.Scanner{
+		Workers:   conf.API.Workers,
+		Cache:     cache,
+		Slots:     solveSlots,
+		FlushRows: SCAN_FLUSH_ROWS,
+		OnFlush: func(jobfile.ScanCounts, bool) error {
+			flush()
+			return nil
+		},
+	}
+	if err := scanner.Run(ctx, reader, writer, &counts); err != nil {
+		return counts, err
+	}
+	return counts, writer.Close()
+}
+
+// Explain4API analyses the job of a request without solving it.
+func Explain4API(req solver.SolveRequest) (solver.Explanation, error) {
+	job, err := withDefaults(req).Job()
+	if err == nil {
+		err = job.Validate()
+	}
+	if err != nil {
+		return solver.Explanation{}, err
+	}
+	return job.Explain(), nil
+}
+
+// PlotQuery holds the options of the plot endpoint, from the query string.
+type PlotQuery struct {
+	From   *float64 `form:"from"`  // Default a
+	To     *float64 `form:"to"`    // Default b
+	Sides  bool     `form:"sides"` // Plot ln(x^n) and ln(K m^x) instead of f
+	Scale  string   `form:"scale"`
+	Width  int      `form:"width"`
+	Height int      `form:"height"`
+}
+
+// Plot4API draws the job of a request as SVG.
+func Plot4API(w io.Writer, req solver.SolveRequest, query PlotQuery) error {
+	job, err := withDefaults(req).Job()
+	if err == nil {
+		err = job.Validate()
+	}
+	if err != nil {
+		return err
+	}
+	from, to := job.A, job.B
+	if query.From != nil {
+		from = *query.From
+	}
+	if query.To != nil {
+		to = *query.To
+	}
+	p, err := plot.New(job, from, to)
+	if err == nil {
+		err = p.SetScale(cmp.Or(query.Scale, plot.SCALE_AUTO))
+	}
+	if err != nil {
+		return err
+	}
+	p.Sides = query.Sides
+	return p.SVG(w, cmp.Or(query.Width, plot.SVG_WIDTH), cmp.Or(query.Height, plot.SVG_HEIGHT))
 }
@@ -1,25 +1,41 @@
 package main
 
 import (
-	"log"
+	"flag"
+	"log/slog"
+	"net"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"github.com/AbdallahZerfaoui/poweq/config"
 	_ "github.com/AbdallahZerfaoui/poweq/docs"
+	"github.com/AbdallahZerfaoui/poweq/solver"
 )
 
-// Global logger
-var logger *log.Logger
+// Global logger, replaced once the configuration is loaded
+var logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-func init() {
-	// Initialize logger or any other setup if needed
-	logger = log.New(os.Stdout, "[API] ", log.LstdFlags)
-	// Set Gin to release mode (optional)
-	gin.SetMode(gin.ReleaseMode)
+// Configuration of the server and defaults of the requests
+var conf *config.Loaded
 
+// Results of the jobs solved so far, nil when the cache is disabled
+var cache *solver.Cache
+
+// Tasks submitted to /jobs
+var tasks *TaskQueue
+
+// Slots of the jobs of batch requests being solved, shared by all
+// requests so that the load stays bounded
+var solveSlots chan struct{}
+
+// fatal logs err and exits, for errors at startup.
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 // @title Power Equation Solver API
@@ -30,20 +46,75 @@ func init() {
 // @contact.email zerfaouiabdallah@gmail.com
 
 func main() {
+	configFile := flag.String("config", "", "Config file (default $"+config.ENV_CONFIG+", else poweq.yaml, .yml, .toml or .json in the working directory)")
+	logLevel := flag.String("log-level", "", "Minimum level of the logs: trace, debug, info, warn or error (default from the config, else info)")
+	logFormat := flag.String("log-format", "", "Format of the logs: text or json (default from the config, else text)")
+	flag.Parse()
+
+	var err error
+	if conf, err = config.Load(*configFile, os.Environ()); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	// PORT is still honoured when the port is not configured
+	if port := os.Getenv("PORT"); port != "" && conf.Sources["api.port"] == config.SOURCE_DEFAULT {
+		if err := conf.Set("api.port", port, config.SOURCE_ENV+" PORT"); err != nil {
+			fatal("Invalid configuration", err)
+		}
+	}
+	if *logLevel != "" {
+		conf.Set("log.level", *logLevel, config.SOURCE_FLAG+" -log-level")
+	}
+	if *logFormat != "" {
+		conf.Set("log.format", *logFormat, config.SOURCE_FLAG+" -log-format")
+	}
+	handler, err := conf.Log.Handler(os.Stdout)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logger = slog.New(handler)
+	solver.SetLogHandler(handler)
+	if cache, err = conf.Cache.Open(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	if cache != nil {
+		logger.Info("Result cache enabled", "size", conf.Cache.Size, "dir", conf.Cache.Dir)
+	}
+	solveSlots = make(chan struct{}, max(conf.API.Workers, 1))
+	store, err := newTaskStore(conf.API.StoreDir)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	if tasks, err = newTaskQueue(store, conf.API.TaskWorkers, conf.API.TaskTTL); err != nil {
+		fatal("Cannot load jobs", err)
+	}
+	gin.SetMode(conf.API.Mode)
+	router := newRouter()
+
+	// Start the server
+	address := net.JoinHostPort(conf.API.Host, strconv.Itoa(conf.API.Port))
+	logger.Info("Listening", "address", address, "mode", conf.API.Mode)
+	if err := router.Run(address); err != nil {
+		fatal("Server stopped", err)
+	}
+}
+
+// newRouter binds the routes of the API to their handlers.
+func newRouter() *gin.Engine {
 	router := gin.Default()
+	router.Use(limitBody(int64(conf.API.MaxBody)))
 
 	router.GET("/healthz", healthHandler)
 	router.POST("/solve", solveHandler)
+	router.POST("/solve/batch", batchHandler)
+	router.POST("/scan", scanHandler)
+	router.POST("/jobs", createTaskHandler)
+	router.GET("/jobs", listTasksHandler)
+	router.GET("/jobs/:id", getTaskHandler)
+	router.DELETE("/jobs/:id", cancelTaskHandler)
+	router.POST("/explain", explainHandler)
+	router.POST("/plot", plotHandler)
 
 	// Swagger docs at /docs
 	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	// Set the port from environment variable or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	// Start the server
-	router.Run(":" + port)
+	return router
 }
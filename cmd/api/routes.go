@@ -1,42 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-)
-
-// Structs for request and response payloads
-type SolveRequest struct {
-	N         float64 `json:"n" binding:"required" example:"2"`
-	M         float64 `json:"m" binding:"required" example:"2.718281828"`
-	K         float64 `json:"k" binding:"required" example:"1"`
-	A         float64 `json:"a" binding:"required" example:"0.1"`
-	B         float64 `json:"b" binding:"required" example:"10"`
-	Tolerance float64 `json:"tolerance" example:"0.000001"`
-	MaxIter   int     `json:"max_iter" example:"100"`
-	Algorithm string  `json:"algorithm" example:"newton"`
-}
-
-type SolveResponse struct {
-	Solutions []APISolution `json:"solutions"`
-}
 
-type APISolution struct {
-	X     float64 `json:"x" example:"2.0"`
-	Steps int     `json:"steps" example:"5"`
-	Error error   `json:"error,omitempty"`
-}
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
 
 // healthHandler handles the health check endpoint.
 // @Summary Health check
-// @Description Returns the API status
+// @Description Returns the API status, with the hits and misses of the result cache when it is enabled
 // @Tags health
 // @Produce  json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string]any
 // @Router /healthz [get]
 func healthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	health := gin.H{"status": "ok"}
+	if cache != nil {
+		health["cache"] = cache.Stats()
+	}
+	c.JSON(http.StatusOK, health)
 }
 
 // Solve godoc
@@ -45,20 +35,31 @@ func healthHandler(c *gin.Context) {
 // @Tags solver
 // @Accept  json
 // @Produce  json
-// @Param   request body SolveRequest true "Solve Request"
-// @Success 200 {object} SolveResponse
+// @Param   request body solver.SolveRequest true "Solve Request"
+// @Param   digits query int false "Significant digits of the roots, 0 or absent for the exact value"
+// @Success 200 {object} solver.SolveResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /solve [post]
 func solveHandler(c *gin.Context) {
-	var req SolveRequest
+	var req solver.SolveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	digits, err := digitsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Call the solver function (to be implemented)
-	result, err := req.Solve4API()
+	result, err := Solve4API(req, digits)
+	if errors.Is(err, ErrInvalidJob) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -66,3 +67,310 @@ func solveHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"result": result})
 }
+
+// digitsQuery reads the significant digits of the roots from the query.
+func digitsQuery(c *gin.Context) (int, error) {
+	digits, err := strconv.Atoi(c.DefaultQuery("digits", "0"))
+	if err == nil {
+		err = solver.CheckDigits(digits)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid digits: %w", err)
+	}
+	return digits, nil
+}
+
+// Batch godoc
+// @Summary Solve a batch of power equations
+// @Description Solves an array of jobs concurrently, on a pool shared by all requests. Results come in the order of the jobs, each with the client id and an echo of the job. A job that is invalid gets an error in its result without failing the others.
+// @Tags solver
+// @Accept  json
+// @Produce  json
+// @Param   request body []solver.SolveRequest true "Jobs"
+// @Param   digits query int false "Significant digits of the roots, 0 or absent for the exact value"
+// @Success 200 {object} BatchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /solve/batch [post]
+func batchHandler(c *gin.Context) {
+	items, digits, ok := bindBatch(c, conf.API.MaxBatch)
+	if !ok {
+		return
+	}
+
+	batch := Batch4API(c.Request.Context(), items, digits, nil)
+	c.JSON(http.StatusOK, gin.H{"result": batch})
+}
+
+// bindBatch reads an array of at most maxJobs jobs from the body and the
+// digits from the query. On failure it responds with the error itself.
+func bindBatch(c *gin.Context, maxJobs int) ([]json.RawMessage, int, bool) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&items); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit)})
+			return nil, 0, false
+		}
+		var notArray *json.UnmarshalTypeError
+		if errors.As(err, &notArray) {
+			err = errors.New("expected an array of jobs")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, 0, false
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty batch"})
+		return nil, 0, false
+	}
+	if len(items) > maxJobs {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("batch of %d jobs, at most %d are accepted", len(items), maxJobs)})
+		return nil, 0, false
+	}
+
+	digits, err := digitsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, 0, false
+	}
+	return items, digits, true
+}
+
+// Jobs godoc
+// @Summary Submit a batch of power equations
+// @Description Queues an array of jobs to be solved in the background, for batches too large or too slow for one request. Poll GET /jobs/{id} for the progress and the results.
+// @Tags jobs
+// @Accept  json
+// @Produce  json
+// @Param   request body []solver.SolveRequest true "Jobs"
+// @Param   digits query int false "Significant digits of the roots, 0 or absent for the exact value"
+// @Success 202 {object} Task
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /jobs [post]
+func createTaskHandler(c *gin.Context) {
+	items, digits, ok := bindBatch(c, conf.API.MaxTask)
+	if !ok {
+		return
+	}
+
+	task, err := tasks.Submit(items, digits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", "/jobs/"+task.Id)
+	c.JSON(http.StatusAccepted, gin.H{"result": task})
+}
+
+// @Summary List the submitted batches
+// @Description Lists the jobs submitted to POST /jobs, oldest first, with their status and progress but not their results
+// @Tags jobs
+// @Produce  json
+// @Param   status query string false "Only the jobs with this status: queued, running, done or canceled"
+// @Success 200 {array} Task
+// @Router /jobs [get]
+func listTasksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"result": tasks.List(c.Query("status"))})
+}
+
+// @Summary Get a submitted batch
+// @Description Returns the status and progress of a job submitted to POST /jobs, with its results once it is done or canceled
+// @Tags jobs
+// @Produce  json
+// @Param   id path string true "Job id"
+// @Success 200 {object} Task
+// @Failure 404 {object} map[string]string
+// @Router /jobs/{id} [get]
+func getTaskHandler(c *gin.Context) {
+	task, err := tasks.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": task})
+}
+
+// @Summary Cancel or delete a submitted batch
+// @Description Cancels a queued or running job, keeping the results of the equations already solved. A job that is done or canceled is deleted.
+// @Tags jobs
+// @Produce  json
+// @Param   id path string true "Job id"
+// @Success 200 {object} Task
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /jobs/{id} [delete]
+func cancelTaskHandler(c *gin.Context) {
+	task, deleted, err := tasks.Cancel(c.Param("id"))
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case deleted:
+		c.Status(http.StatusNoContent)
+	default:
+		c.JSON(http.StatusOK, gin.H{"result": task})
+	}
+}
+
+// Content types of the results of an upload, the first being the default
+const (
+	MIME_CSV    = "text/csv"
+	MIME_NDJSON = "application/x-ndjson"
+)
+
+var scanFormats = map[string]string{
+	MIME_CSV:     jobfile.FORMAT_CSV,
+	gin.MIMEJSON: jobfile.FORMAT_JSON,
+	MIME_NDJSON:  jobfile.FORMAT_NDJSON,
+}
+
+// Scan godoc
+// @Summary Solve an uploaded jobs file
+// @Description Solves the rows of a CSV file in the input format of poweq scan, sent as the "file" field of a form, and streams back the results as poweq scan writes them: CSV by default, a JSON array or NDJSON when the Accept header asks for it. Rows that cannot be read are rejected in the results without failing the others.
+// @Tags solver
+// @Accept  multipart/form-data
+// @Produce  text/csv
+// @Produce  json
+// @Produce  application/x-ndjson
+// @Param   file formData file true "Jobs file"
+// @Param   digits query int false "Significant digits of the roots, 0 or absent for the exact value"
+// @Param   delim query string false "Field delimiter of the file and of the CSV results, default ',' ('tab' for TSV)"
+// @Param   comment query string false "Lines starting with this character are ignored, default '#' (empty to disable)"
+// @Param   strict-columns query bool false "Fail on unknown columns instead of copying them to the results"
+// @Success 200 {string} string "Results"
+// @Failure 400 {object} map[string]string
+// @Failure 406 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /scan [post]
+func scanHandler(c *gin.Context) {
+	mime := c.NegotiateFormat(MIME_CSV, gin.MIMEJSON, MIME_NDJSON)
+	if mime == "" {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": fmt.Sprintf("results are served as %s, %s or %s", MIME_CSV, gin.MIMEJSON, MIME_NDJSON)})
+		return
+	}
+	digits, err := digitsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var query ScanQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := query.Options(digits)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected a jobs file in the form field \"file\": %v", err)})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	reader, err := jobfile.NewReader(file, jobfile.FORMAT_CSV, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Results are streamed: once the first ones are sent, errors can
+	// only cut the response short
+	format := scanFormats[mime]
+	c.Header("Content-Type", mime+"; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "results."+format))
+	c.Status(http.StatusOK)
+	writer := jobfile.NewWriter(c.Writer, format, opts, reader.ExtraColumns(), 0)
+	stats, err := Scan4API(c.Request.Context(), reader, writer, c.Writer.Flush)
+	if err != nil {
+		logger.Error("Upload aborted", "file", header.Filename, "jobs", stats.Jobs, "error", err)
+		return
+	}
+	logger.Info("Upload solved", "file", header.Filename, "jobs", stats.Jobs, "rejected", stats.Rejected)
+}
+
+// limitBody caps the size of request bodies, 0 for no limit.
+func limitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// Explain godoc
+// @Summary Explain a power equation
+// @Description Analyses x^n = K * m^x without solving it: log form, x_limit, expected number of roots with the reasoning, initial guesses and intervals of the methods, and the Lambert W closed form
+// @Tags solver
+// @Accept  json
+// @Produce  json
+// @Param   request body solver.SolveRequest true "Solve Request"
+// @Success 200 {object} solver.Explanation
+// @Failure 400 {object} map[string]string
+// @Router /explain [post]
+func explainHandler(c *gin.Context) {
+	var req solver.SolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	explanation, err := Explain4API(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": explanation})
+}
+
+// Plot godoc
+// @Summary Plot a power equation
+// @Description Draws f(x) = n ln(x) - ln(K) - x ln(m), or both sides of x^n = K * m^x, as SVG with the roots, x_limit and initial guesses marked
+// @Tags solver
+// @Accept  json
+// @Produce  image/svg+xml
+// @Param   request body solver.SolveRequest true "Solve Request"
+// @Param   from query number false "Left end of the plot, default a"
+// @Param   to query number false "Right end of the plot, default b"
+// @Param   sides query bool false "Plot ln(x^n) and ln(K m^x) instead of f"
+// @Param   scale query string false "Scale of x: auto, linear or log"
+// @Param   width query int false "Width in pixels, default 800"
+// @Param   height query int false "Height in pixels, default 480"
+// @Success 200 {string} string "SVG image"
+// @Failure 400 {object} map[string]string
+// @Router /plot [post]
+func plotHandler(c *gin.Context) {
+	var req solver.SolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var query PlotQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var svg bytes.Buffer
+	if err := Plot4API(&svg, req, query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/svg+xml", svg.Bytes())
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// setupAPI sets the globals of the server up as main does, with the
+// default configuration changed by configure, and returns its router.
+func setupAPI(t *testing.T, configure func(c *config.Config)) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var err error
+	if conf, err = config.Load("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(&conf.Config)
+	}
+	if cache, err = conf.Cache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	solveSlots = make(chan struct{}, max(conf.API.Workers, 1))
+	store, err := newTaskStore(conf.API.StoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks, err = newTaskQueue(store, conf.API.TaskWorkers, conf.API.TaskTTL); err != nil {
+		t.Fatal(err)
+	}
+	return newRouter()
+}
+
+// request sends a request to router and returns the response.
+func request(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode reads the result of a response into result and returns its
+// error, empty if there is none.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, result any) string {
+	t.Helper()
+	envelope := struct {
+		Result any    `json:"result"`
+		Error  string `json:"error"`
+	}{Result: result}
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	return envelope.Error
+}
+
+func TestSolveHandler(t *testing.T) {
+	router := setupAPI(t, nil)
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		err    string
+	}{
+		{"valid job", `{"id":1,"n":2,"m":2,"k":1,"a":0.1,"b":10}`, http.StatusOK, ""},
+		{"invalid job", `{"n":2,"m":0.5,"k":1,"a":0.1,"b":100}`, http.StatusBadRequest, "invalid job: m must be at least 1"},
+		{"unknown algorithm", `{"n":2,"m":2,"k":1,"a":0.1,"b":10,"algorithm":"foo"}`, http.StatusBadRequest, `invalid job: field "algorithm": unknown algorithm "foo"`},
+		{"missing field", `{"n":2}`, http.StatusBadRequest, "required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := cache.Stats()
+			rec := request(router, http.MethodPost, "/solve", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp solver.SolveResponse
+			errText := decode(t, rec, &resp)
+			if (tt.err == "") != (errText == "") || !strings.Contains(errText, tt.err) {
+				t.Errorf("error = %q, want %q", errText, tt.err)
+			}
+			if tt.err == "" && (resp.Id != 1 || len(resp.Solutions) == 0) {
+				t.Errorf("result = %+v, want the solutions of job 1", resp)
+			}
+			// Rejected requests never reach the cache
+			if after := cache.Stats(); tt.err != "" && after != before {
+				t.Errorf("cache stats %+v, then %+v", before, after)
+			}
+		})
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	router := setupAPI(t, func(c *config.Config) { c.API.MaxBatch = 3 })
+	const valid = `{"id":1,"n":2,"m":2,"k":1,"a":0.1,"b":10}`
+	tests := []struct {
+		name   string
+		query  string
+		body   string
+		status int
+		errors []string // Of each result, or of the request on failure
+		x      float64  // First root of the first result, 0 not to check it
+	}{
+		{"valid jobs", "", `[` + valid + `,{"id":2,"n":3,"m":3,"k":1,"a":2.6,"b":10}]`, http.StatusOK, []string{"", ""}, 2},
+		{"rounded roots", "?digits=3", `[{"id":1,"n":3,"m":3,"k":1,"a":1,"b":2.9}]`, http.StatusOK, []string{""}, 2.48},
+		{"invalid job", "", `[` + valid + `,{"id":2,"n":2,"m":0.5,"k":1,"a":0.1,"b":100}]`, http.StatusOK, []string{"", "invalid job: m must be at least 1"}, 2},
+		{"unknown algorithm", "", `[` + valid + `,{"id":2,"n":2,"m":2,"k":1,"a":0.1,"b":10,"algorithm":"foo"}]`, http.StatusOK, []string{"", `unknown algorithm "foo"`}, 2},
+		{"missing field", "", `[` + valid + `,{"id":2,"n":2}]`, http.StatusOK, []string{"", "required"}, 0},
+		{"not a job", "", `[` + valid + `,"job"]`, http.StatusOK, []string{"", "cannot unmarshal"}, 0},
+		{"not an array", "", valid, http.StatusBadRequest, []string{"expected an array of jobs"}, 0},
+		{"empty batch", "", `[]`, http.StatusBadRequest, []string{"empty batch"}, 0},
+		{"too many jobs", "", `[` + strings.Repeat(valid+",", 3) + valid + `]`, http.StatusRequestEntityTooLarge, []string{"at most 3"}, 0},
+		{"invalid digits", "?digits=x", `[` + valid + `]`, http.StatusBadRequest, []string{"digits"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(router, http.MethodPost, "/solve/batch"+tt.query, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var batch BatchResponse
+			errText := decode(t, rec, &batch)
+			if tt.status != http.StatusOK {
+				if !strings.Contains(errText, tt.errors[0]) {
+					t.Errorf("error = %q, want %q", errText, tt.errors[0])
+				}
+				return
+			}
+
+			if len(batch.Results) != len(tt.errors) {
+				t.Fatalf("%d results, want %d", len(batch.Results), len(tt.errors))
+			}
+			nbErrors := 0
+			for i, want := range tt.errors {
+				resp := batch.Results[i]
+				if (want == "") != (resp.Error == "") || !strings.Contains(resp.Error, want) {
+					t.Errorf("result %d: error = %q, want %q", i+1, resp.Error, want)
+				}
+				if want != "" {
+					nbErrors++
+				} else if resp.Id != i+1 || resp.Job == nil || len(resp.Solutions) == 0 {
+					t.Errorf("result %d = %+v, want the solutions of job %d", i+1, resp, i+1)
+				}
+			}
+			if batch.Errors != nbErrors {
+				t.Errorf("Errors = %d, want %d", batch.Errors, nbErrors)
+			}
+			if x := batch.Results[0].Solutions[0].X; tt.x != 0 && math.Abs(x-tt.x) > 1e-9 {
+				t.Errorf("x = %v, want %v", x, tt.x)
+			}
+		})
+	}
+}
+
+// upload posts data to /scan as the file field of a form, unless field
+// is another one.
+func upload(router *gin.Engine, target, accept, field, data string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile(field, "jobs.csv")
+	part.Write([]byte(data))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// scanResults reads the id and error of each result of a /scan response,
+// one per root in CSV, one per job in JSON.
+func scanResults(t *testing.T, mime string, body []byte) (ids []int, errs []string) {
+	t.Helper()
+	if mime == MIME_CSV {
+		rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil || len(rows) == 0 {
+			t.Fatalf("results not CSV: %v: %s", err, body)
+		}
+		errorColumn := slices.Index(rows[0], "Error")
+		for _, row := range rows[1:] {
+			id, _ := strconv.Atoi(row[0])
+			ids = append(ids, id)
+			errs = append(errs, strings.TrimPrefix(row[errorColumn], "<nil>"))
+		}
+		return ids, errs
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var results []solver.SolveResponse
+	if mime == gin.MIMEJSON {
+		if err := decoder.Decode(&results); err != nil {
+			t.Fatalf("results not a JSON array: %v: %s", err, body)
+		}
+	}
+	for decoder.More() {
+		var resp solver.SolveResponse
+		if err := decoder.Decode(&resp); err != nil {
+			t.Fatalf("results not NDJSON: %v: %s", err, body)
+		}
+		results = append(results, resp)
+	}
+	for _, resp := range results {
+		ids = append(ids, resp.Id)
+		errs = append(errs, resp.Error)
+	}
+	return ids, errs
+}
+
+func TestScanHandler(t *testing.T) {
+	router := setupAPI(t, func(c *config.Config) { c.API.MaxBody = 1024 })
+	// The second row is rejected, the first job has two roots
+	const jobs = "Id,N,M,K,A,B,tag\n1,2,2,1,0.1,10,first\n2,x,2,1,0.1,10,bad\n3,3,3,1,1,2.9,third\n"
+	tests := []struct {
+		name, accept, mime string
+		ids                []int
+	}{
+		{"csv by default", "", MIME_CSV, []int{1, 1, 2, 3}},
+		{"csv", MIME_CSV, MIME_CSV, []int{1, 1, 2, 3}},
+		{"json", gin.MIMEJSON, gin.MIMEJSON, []int{1, 2, 3}},
+		{"ndjson", MIME_NDJSON, MIME_NDJSON, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := upload(router, "/scan", tt.accept, "file", jobs)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.mime) {
+				t.Errorf("Content-Type = %q, want %s", contentType, tt.mime)
+			}
+			ids, errs := scanResults(t, tt.mime, rec.Body.Bytes())
+			if !slices.Equal(ids, tt.ids) {
+				t.Fatalf("ids = %v, want %v", ids, tt.ids)
+			}
+			for i, id := range ids {
+				want := ""
+				if id == 2 {
+					want = "rejected (line 3)"
+				}
+				if (want == "") != (errs[i] == "") || !strings.HasPrefix(errs[i], want) {
+					t.Errorf("result %d of job %d: error %q, want %q", i+1, id, errs[i], want)
+				}
+			}
+			if tt.mime == MIME_CSV && !strings.Contains(rec.Body.String(), ",third\n") {
+				t.Errorf("passthrough column lost: %s", rec.Body.String())
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name, accept, field, data string
+		status                    int
+		err                       string
+	}{
+		{"not acceptable", "image/png", "file", jobs, http.StatusNotAcceptable, "text/csv"},
+		{"no file", "", "jobs", jobs, http.StatusBadRequest, `form field "file"`},
+		{"invalid header", "", "file", "Id,N\n1,2\n", http.StatusBadRequest, "invalid header"},
+		{"too large", "", "file", jobs + strings.Repeat("4,2,2,1,0.1,10,more\n", 50), http.StatusRequestEntityTooLarge, "larger than 1024 bytes"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := upload(router, "/scan", tt.accept, tt.field, tt.data)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if errText := decode(t, rec, nil); !strings.Contains(errText, tt.err) {
+				t.Errorf("error = %q, want %q", errText, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TaskStore keeps the tasks of /jobs so that they survive restarts. The
+// queue holds the live tasks in memory and saves each one on every change
+// of status; a store only needs to give them back at startup.
+type TaskStore interface {
+	Save(task Task) error
+	Delete(id string) error
+	LoadAll() ([]Task, error)
+}
+
+// newTaskStore opens the store of the configuration: a directory when
+// one is set, else memory only.
+func newTaskStore(dir string) (TaskStore, error) {
+	if dir == "" {
+		return memoryStore{}, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("task store: %w", err)
+	}
+	return fileStore{dir}, nil
+}
+
+// memoryStore keeps nothing: the tasks live as long as the process, in
+// the queue.
+type memoryStore struct{}
+
+func (memoryStore) Save(Task) error          { return nil }
+func (memoryStore) Delete(string) error      { return nil }
+func (memoryStore) LoadAll() ([]Task, error) { return nil, nil }
+
+// fileStore keeps each task as a JSON file named after its id.
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save replaces the file of the task atomically, so that a crash while
+// saving leaves the previous state intact.
+func (s fileStore) Save(task Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(task.Id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(task.Id))
+}
+
+func (s fileStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s fileStore) LoadAll() ([]Task, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, fmt.Errorf("task store %s: %w", entry.Name(), err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Status of a task
+const (
+	TASK_QUEUED   = "queued"
+	TASK_RUNNING  = "running"
+	TASK_DONE     = "done"
+	TASK_CANCELED = "canceled"
+)
+
+var ErrTaskNotFound = errors.New("no such job")
+
+// Task is a batch submitted to POST /jobs and solved in the background.
+// Jobs are kept until the task finishes, so that a task interrupted by a
+// restart runs again; they are left out of the responses, the results
+// echoing them.
+type Task struct {
+	Id       string                 `json:"id"`
+	Status   string                 `json:"status"`
+	Total    int                    `json:"total"`  // Jobs in the task
+	Done     int                    `json:"done"`   // Jobs completed so far
+	Errors   int                    `json:"errors"` // Jobs rejected, once finished
+	Digits   int                    `json:"digits"`
+	Created  time.Time              `json:"created"`
+	Started  *time.Time             `json:"started,omitempty"`
+	Finished *time.Time             `json:"finished,omitempty"`
+	Jobs     []json.RawMessage      `json:"jobs,omitempty" swaggerignore:"true"`
+	Results  []solver.SolveResponse `json:"results,omitempty"` // In the order of the jobs, once finished
+}
+
+func (t Task) finished() bool {
+	return t.Status == TASK_DONE || t.Status == TASK_CANCELED
+}
+
+// view is the task as returned to clients, without its jobs.
+func (t Task) view() Task {
+	t.Jobs = nil
+	return t
+}
+
+// TaskQueue runs the tasks in the order they were submitted on a pool of
+// workers. Their jobs are solved through Batch4API, so they share the
+// solving slots of the batch requests. Finished tasks are deleted once
+// older than ttl, unless it is 0.
+type TaskQueue struct {
+	store TaskStore
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	ready   *sync.Cond
+	tasks   map[string]*Task
+	queue   []string                      // Ids of the queued tasks
+	cancels map[string]context.CancelFunc // Of the running tasks
+}
+
+// newTaskQueue loads the tasks of store and starts workers goroutines.
+// Tasks that were queued or running when the API stopped are run again.
+func newTaskQueue(store TaskStore, workers int, ttl time.Duration) (*TaskQueue, error) {
+	q := &TaskQueue{store: store, ttl: ttl, tasks: map[string]*Task{}, cancels: map[string]context.CancelFunc{}}
+	q.ready = sync.NewCond(&q.mu)
+
+	tasks, err := store.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(tasks, func(a, b Task) int { return a.Created.Compare(b.Created) })
+	for _, task := range tasks {
+		if !task.finished() {
+			task.Status, task.Done, task.Started, task.Results = TASK_QUEUED, 0, nil, nil
+			q.queue = append(q.queue, task.Id)
+		}
+		q.tasks[task.Id] = &task
+	}
+	q.prune()
+	if len(q.queue) > 0 {
+		logger.Info("Resuming jobs", "count", len(q.queue))
+	}
+
+	for range max(workers, 1) {
+		go q.work()
+	}
+	return q, nil
+}
+
+func newTaskId() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Submit queues the jobs of a batch request.
+func (q *TaskQueue) Submit(items []json.RawMessage, digits int) (Task, error) {
+	task := &Task{
+		Id: newTaskId(), Status: TASK_QUEUED, Total: len(items), Digits: digits,
+		Created: time.Now().UTC(), Jobs: items,
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	if err := q.store.Save(*task); err != nil {
+		return Task{}, err
+	}
+	q.tasks[task.Id] = task
+	q.queue = append(q.queue, task.Id)
+	q.ready.Signal()
+	return task.view(), nil
+}
+
+// Get returns the task id with its results.
+func (q *TaskQueue) Get(id string) (Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	task, ok := q.tasks[id]
+	if !ok {
+		return Task{}, ErrTaskNotFound
+	}
+	return task.view(), nil
+}
+
+// List returns the tasks without their results, oldest first, only those
+// with status unless it is empty.
+func (q *TaskQueue) List(status string) []Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	tasks := []Task{}
+	for _, task := range q.tasks {
+		if status == "" || task.Status == status {
+			summary := task.view()
+			summary.Results = nil
+			tasks = append(tasks, summary)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b Task) int {
+		return cmp.Or(a.Created.Compare(b.Created), cmp.Compare(a.Id, b.Id))
+	})
+	return tasks
+}
+
+// Cancel stops a queued or running task, keeping the results of the jobs
+// already solved. A finished task is deleted instead, which reports true.
+func (q *TaskQueue) Cancel(id string) (Task, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+	task, ok := q.tasks[id]
+	if !ok {
+		return Task{}, false, ErrTaskNotFound
+	}
+	switch task.Status {
+	case TASK_QUEUED:
+		q.finish(task, TASK_CANCELED)
+		q.queue = slices.DeleteFunc(q.queue, func(queued string) bool { return queued == id })
+	case TASK_RUNNING:
+		// The worker records the results, the jobs not started failing
+		q.cancels[id]()
+	default:
+		if err := q.store.Delete(id); err != nil {
+			return Task{}, false, err
+		}
+		delete(q.tasks, id)
+		return task.view(), true, nil
+	}
+	return task.view(), false, nil
+}
+
+// finish ends a task and saves it without its jobs, with q.mu held.
+func (q *TaskQueue) finish(task *Task, status string) {
+	now := time.Now().UTC()
+	task.Status, task.Finished, task.Jobs = status, &now, nil
+	if err := q.store.Save(*task); err != nil {
+		logger.Error("Cannot save job", "id", task.Id, "error", err)
+	}
+}
+
+// prune deletes the tasks finished longer than the ttl ago, with q.mu
+// held. It runs on every use of the queue, which is enough to bound its
+// memory: tasks are only added by Submit.
+func (q *TaskQueue) prune() {
+	if q.ttl <= 0 {
+		return
+	}
+	expired := time.Now().Add(-q.ttl)
+	for id, task := range q.tasks {
+		if !task.finished() || task.Finished.After(expired) {
+			continue
+		}
+		if err := q.store.Delete(id); err != nil {
+			logger.Error("Cannot delete job", "id", id, "error", err)
+			continue
+		}
+		delete(q.tasks, id)
+	}
+}
+
+// work runs the queued tasks one at a time.
+func (q *TaskQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.queue) == 0 {
+			q.ready.Wait()
+		}
+		task := q.tasks[q.queue[0]]
+		q.queue = q.queue[1:]
+
+		ctx, cancel := context.WithCancel(context.Background())
+		q.cancels[task.Id] = cancel
+		now := time.Now().UTC()
+		task.Status, task.Started = TASK_RUNNING, &now
+		if err := q.store.Save(*task); err != nil {
+			logger.Error("Cannot save job", "id", task.Id, "error", err)
+		}
+		jobs, digits := task.Jobs, task.Digits
+		q.mu.Unlock()
+
+		logger.Info("Job started", "id", task.Id, "jobs", len(jobs))
+		batch := Batch4API(ctx, jobs, digits, func() {
+			q.mu.Lock()
+			task.Done++
+			q.mu.Unlock()
+		})
+
+		q.mu.Lock()
+		delete(q.cancels, task.Id)
+		task.Results, task.Errors = batch.Results, batch.Errors
+		status := TASK_DONE
+		if ctx.Err() != nil {
+			status = TASK_CANCELED
+		}
+		q.finish(task, status)
+		logger.Info("Job finished", "id", task.Id, "status", status, "errors", task.Errors, "duration", task.Finished.Sub(now))
+		q.mu.Unlock()
+		cancel()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+)
+
+// getTask polls GET /jobs/id until done accepts the task.
+func getTask(t *testing.T, router *gin.Engine, id string, done func(Task) bool) Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var task Task
+		rec := request(router, http.MethodGet, "/jobs/"+id, "")
+		if errText := decode(t, rec, &task); rec.Code != http.StatusOK {
+			t.Fatalf("GET /jobs/%s: status %d: %s", id, rec.Code, errText)
+		}
+		if done(task) {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /jobs/%s: still %s", id, task.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// submit posts jobs to /jobs and returns the queued task.
+func submit(t *testing.T, router *gin.Engine, jobs string) Task {
+	t.Helper()
+	var task Task
+	rec := request(router, http.MethodPost, "/jobs", jobs)
+	if errText := decode(t, rec, &task); rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs: status %d: %s", rec.Code, errText)
+	}
+	if location := rec.Header().Get("Location"); location != "/jobs/"+task.Id {
+		t.Errorf("Location = %q, want /jobs/%s", location, task.Id)
+	}
+	return task
+}
+
+func TestTaskHandlers(t *testing.T) {
+	router := setupAPI(t, func(c *config.Config) { c.API.MaxTask = 3 })
+
+	task := submit(t, router, `[{"id":1,"n":2,"m":2,"k":1,"a":0.1,"b":10},{"id":2,"n":2,"m":0.5,"k":1,"a":0.1,"b":100},{"id":3,"n":2,"m":2,"k":1,"a":3,"b":10}]`)
+	if task.Total != 3 || task.Jobs != nil {
+		t.Errorf("submitted task = %+v, want 3 jobs left out", task)
+	}
+	task = getTask(t, router, task.Id, Task.finished)
+	if task.Status != TASK_DONE || task.Done != 3 || task.Errors != 1 || len(task.Results) != 3 {
+		t.Fatalf("finished task = %+v, want 3 jobs done, 1 failed", task)
+	}
+	for i, resp := range task.Results {
+		if resp.Id != i+1 || (resp.Error != "") != (i == 1) {
+			t.Errorf("result %d = %+v", i+1, resp)
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		ids   int
+	}{
+		{"", 1},
+		{"?status=" + TASK_DONE, 1},
+		{"?status=" + TASK_QUEUED, 0},
+	} {
+		var list []Task
+		decode(t, request(router, http.MethodGet, "/jobs"+tt.query, ""), &list)
+		if len(list) != tt.ids {
+			t.Errorf("GET /jobs%s: %d jobs, want %d", tt.query, len(list), tt.ids)
+		}
+		for _, listed := range list {
+			if listed.Id != task.Id || listed.Results != nil {
+				t.Errorf("GET /jobs%s: %+v, want the job without results", tt.query, listed)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		method, target, body string
+		status               int
+		err                  string
+	}{
+		{http.MethodPost, "/jobs", `[{"n":2},{"n":2},{"n":2},{"n":2}]`, http.StatusRequestEntityTooLarge, "at most 3"},
+		{http.MethodPost, "/jobs", `[]`, http.StatusBadRequest, "empty batch"},
+		{http.MethodGet, "/jobs/unknown", "", http.StatusNotFound, ErrTaskNotFound.Error()},
+		{http.MethodDelete, "/jobs/" + task.Id, "", http.StatusNoContent, ""},
+		{http.MethodGet, "/jobs/" + task.Id, "", http.StatusNotFound, ErrTaskNotFound.Error()},
+		{http.MethodDelete, "/jobs/" + task.Id, "", http.StatusNotFound, ErrTaskNotFound.Error()},
+	} {
+		rec := request(router, tt.method, tt.target, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, rec.Code, tt.status)
+		}
+		if tt.err != "" {
+			if errText := decode(t, rec, nil); !strings.Contains(errText, tt.err) {
+				t.Errorf("%s %s: error %q, want %q", tt.method, tt.target, errText, tt.err)
+			}
+		}
+	}
+}
+
+func TestTaskCancel(t *testing.T) {
+	router := setupAPI(t, func(c *config.Config) { c.API.Workers, c.API.TaskWorkers = 1, 1 })
+	const jobs = `[{"id":1,"n":2,"m":2,"k":1,"a":0.1,"b":10},{"id":2,"n":2,"m":2,"k":1,"a":3,"b":10}]`
+
+	// With the only solve slot taken, the first task cannot get past its
+	// first job and the second one stays queued
+	solveSlots <- struct{}{}
+	running := submit(t, router, jobs)
+	getTask(t, router, running.Id, func(task Task) bool { return task.Status == TASK_RUNNING })
+	queued := submit(t, router, jobs)
+
+	var canceled Task
+	rec := request(router, http.MethodDelete, "/jobs/"+queued.Id, "")
+	if decode(t, rec, &canceled); rec.Code != http.StatusOK || canceled.Status != TASK_CANCELED || canceled.Done != 0 {
+		t.Errorf("canceling a queued job: status %d, %+v", rec.Code, canceled)
+	}
+	rec = request(router, http.MethodDelete, "/jobs/"+running.Id, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("canceling a running job: status %d", rec.Code)
+	}
+	<-solveSlots
+
+	// The job waiting for the slot completes, the next one fails
+	task := getTask(t, router, running.Id, Task.finished)
+	if task.Status != TASK_CANCELED || len(task.Results) != 2 || task.Errors != 1 || task.Results[1].Error == "" {
+		t.Errorf("canceled running job = %+v, want the second job failed", task)
+	}
+	if task := getTask(t, router, queued.Id, Task.finished); task.Status != TASK_CANCELED || task.Started != nil {
+		t.Errorf("canceled queued job = %+v, want it never started", task)
+	}
+}
+
+func TestTaskExpiry(t *testing.T) {
+	dir := t.TempDir()
+	router := setupAPI(t, func(c *config.Config) { c.API.StoreDir, c.API.TaskTTL = dir, time.Hour })
+	task := getTask(t, router, submit(t, router, `[{"id":1,"n":2,"m":2,"k":1,"a":0.1,"b":10}]`).Id, Task.finished)
+
+	// A finished task keeps its results only, in memory and in the store
+	path := fileStore{dir}.path(task.Id)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks.mu.Lock()
+	jobs := tasks.tasks[task.Id].Jobs
+	tasks.mu.Unlock()
+	if jobs != nil || strings.Contains(string(data), `"jobs"`) {
+		t.Errorf("jobs of a finished task kept: %d in memory, %s", len(jobs), data)
+	}
+
+	// Finished two hours ago, the task is gone from both
+	tasks.mu.Lock()
+	finished := task.Finished.Add(-2 * time.Hour)
+	tasks.tasks[task.Id].Finished = &finished
+	tasks.mu.Unlock()
+	if rec := request(router, http.MethodGet, "/jobs/"+task.Id, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET an expired job: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expired job still stored: %v", err)
+	}
+}
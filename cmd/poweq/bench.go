@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Settings of the reference solve: bisection down to 12 significant
+// digits, far below the tolerance of the jobs themselves
+const (
+	REFERENCE_PRECISION = 12
+	REFERENCE_TOL       = 1e-300 // Only an exact zero stops on the residual
+	REFERENCE_MAX_ITER  = 200
+)
+
+// benchConfig holds the settings of a bench run, as parsed from the flags.
+type benchConfig struct {
+	Methods []string
+	Runs    int     // Times each method solves the whole set, for steadier timings
+	RelTol  float64 // Relative distance under which a root matches the reference
+}
+
+// benchStats are the figures of one method over the whole set.
+type benchStats struct {
+	Method   string
+	Jobs     int
+	Solved   int // Jobs with at least one root
+	Compared int // Jobs whose reference has roots
+	Agreed   int // Compared jobs whose roots all match the reference
+	Roots    int
+	Steps    int // Total over the roots
+	Elapsed  time.Duration
+	Allocs   uint64
+	Solves   int // Jobs times runs
+}
+
+func (s benchStats) SuccessRate() float64   { return ratio(s.Solved, s.Jobs) }
+func (s benchStats) AgreementRate() float64 { return ratio(s.Agreed, s.Compared) }
+func (s benchStats) MeanSteps() float64     { return ratio(s.Steps, s.Roots) }
+
+func (s benchStats) TimePerSolve() time.Duration {
+	if s.Solves == 0 {
+		return 0
+	}
+	return s.Elapsed / time.Duration(s.Solves)
+}
+
+func (s benchStats) AllocsPerSolve() float64 {
+	if s.Solves == 0 {
+		return 0
+	}
+	return float64(s.Allocs) / float64(s.Solves)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// roots keeps the roots found, dropping the failed attempts.
+func roots(results []solver.Result) []float64 {
+	var xs []float64
+	for _, result := range results {
+		if result.Err == nil {
+			xs = append(xs, result.X)
+		}
+	}
+	return xs
+}
+
+// reference solves job far more accurately than its own settings ask for.
+func reference(job solver.Job) []float64 {
+	job.Tol, job.MaxIter = REFERENCE_TOL, REFERENCE_MAX_ITER
+	job.Precision, job.Criterion, job.Timeout = REFERENCE_PRECISION, solver.CRITERION_DEFAULT, 0
+	return roots(job.Process(solver.METHOD_BISECTION))
+}
+
+// agree reports whether xs are the reference roots, within relTol.
+// Roots are matched in order, so finding the same root twice does not
+// pass for finding two close roots.
+func agree(xs, ref []float64, relTol float64) bool {
+	if len(xs) != len(ref) {
+		return false
+	}
+	xs, ref = slices.Sorted(slices.Values(xs)), slices.Sorted(slices.Values(ref))
+	for i, x := range xs {
+		if math.Abs(x-ref[i]) > relTol*math.Max(1, math.Abs(ref[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// runBench solves jobs with every method of cfg and compares them.
+// Solver logs are discarded so that they do not weigh on the timings.
+func runBench(jobs []solver.Job, cfg benchConfig) []benchStats {
+	defer solver.SetLogHandler(solver.LogHandler())
+	solver.SetLogHandler(nil)
+
+	refs := make([][]float64, len(jobs))
+	for i, job := range jobs {
+		refs[i] = reference(job)
+	}
+
+	var all []benchStats
+	results := make([][]solver.Result, len(jobs))
+	for _, method := range cfg.Methods {
+		stats := benchStats{Method: method, Jobs: len(jobs), Solves: len(jobs) * cfg.Runs}
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for run := 0; run < cfg.Runs; run++ {
+			for i, job := range jobs {
+				results[i] = job.Process(method)
+			}
+		}
+		stats.Elapsed = time.Since(start)
+		runtime.ReadMemStats(&after)
+		stats.Allocs = after.Mallocs - before.Mallocs
+
+		// Results of the last run, they are the same at every run
+		for i, jobResults := range results {
+			xs := roots(jobResults)
+			if len(xs) > 0 {
+				stats.Solved++
+			}
+			for _, result := range jobResults {
+				if result.Err == nil {
+					stats.Roots++
+					stats.Steps += result.Steps
+				}
+			}
+			if len(refs[i]) > 0 {
+				stats.Compared++
+				if agree(xs, refs[i], cfg.RelTol) {
+					stats.Agreed++
+				}
+			}
+		}
+		all = append(all, stats)
+	}
+	return all
+}
+
+var benchColumns = []string{"Method", "Jobs", "SuccessRate", "AgreementRate", "MeanSteps", "NsPerSolve", "AllocsPerSolve"}
+
+func (s benchStats) fields() []string {
+	return []string{
+		s.Method,
+		strconv.Itoa(s.Jobs),
+		solver.FormatFloat(s.SuccessRate(), solver.DIGITS_EXACT),
+		solver.FormatFloat(s.AgreementRate(), solver.DIGITS_EXACT),
+		solver.FormatFloat(s.MeanSteps(), solver.DIGITS_EXACT),
+		strconv.FormatInt(s.TimePerSolve().Nanoseconds(), 10),
+		solver.FormatFloat(s.AllocsPerSolve(), solver.DIGITS_EXACT),
+	}
+}
+
+func writeBenchCSV(w io.Writer, all []benchStats) error {
+	writer := csv.NewWriter(w)
+	writer.Write(benchColumns)
+	for _, stats := range all {
+		writer.Write(stats.fields())
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeBenchTable(w io.Writer, all []benchStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tJOBS\tSUCCESS\tAGREEMENT\tMEAN STEPS\tTIME/SOLVE\tALLOCS/SOLVE")
+	for _, s := range all {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%.1f%%\t%.1f\t%v\t%.1f\n",
+			s.Method, s.Jobs, 100*s.SuccessRate(), 100*s.AgreementRate(),
+			s.MeanSteps(), s.TimePerSolve(), s.AllocsPerSolve())
+	}
+	return tw.Flush()
+}
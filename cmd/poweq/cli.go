@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Exit codes, the same for every command
+const (
+	EXIT_OK          = 0
+	EXIT_FAILURE     = 1 // Unclassified failure, or a regression found by diff
+	EXIT_USAGE       = 2 // Bad command line, as the flag package
+	EXIT_INVALID     = 3 // Invalid job, input file or configuration
+	EXIT_NO_SOLUTION = 4 // The equation has no solution
+	EXIT_PARTIAL     = 5 // The batch completed, but rows were rejected
+	EXIT_IO          = 6 // A file could not be read or written
+)
+
+// Kinds of errors, tagged on the errors of the commands
+var (
+	ErrUsage        = errors.New("usage error")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrPartialBatch = errors.New("batch partially failed")
+)
+
+// Formats of the error reported when a command fails
+const (
+	ERROR_FORMAT_TEXT = "text"
+	ERROR_FORMAT_JSON = "json"
+)
+
+// Flags given before the command
+var (
+	globalFlags = flag.NewFlagSet("poweq", flag.ContinueOnError)
+	errorFormat = globalFlags.String("error-format", ERROR_FORMAT_TEXT, "Format of the error of a failed command, on stderr: text or json")
+	logLevel    = globalFlags.String("log-level", "", "Minimum level of the logs: trace, debug, info, warn or error (default from the config, else info)")
+	logFormat   = globalFlags.String("log-format", "", "Format of the logs: text or json (default from the config, else text)")
+)
+
+// kindError tags an error with its kind, keeping its message.
+type kindError struct {
+	kind, err error
+}
+
+func (e kindError) Error() string   { return e.err.Error() }
+func (e kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// tagged marks err as being of kind, nil staying nil.
+func tagged(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return kindError{kind, err}
+}
+
+// usageErrorf is an error in the command line.
+func usageErrorf(format string, args ...any) error {
+	return tagged(ErrUsage, fmt.Errorf(format, args...))
+}
+
+// exitCode classifies an error, with the name written in the JSON error.
+func exitCode(err error) (int, string) {
+	var pathErr *fs.PathError
+	var parseErr *csv.ParseError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case err == nil:
+		return EXIT_OK, ""
+	case errors.Is(err, ErrUsage):
+		return EXIT_USAGE, "usage"
+	case errors.Is(err, ErrRegression):
+		return EXIT_FAILURE, "regression"
+	case errors.As(err, &pathErr):
+		// Checked first, reading an invalid file may fail on I/O
+		return EXIT_IO, "io"
+	case errors.Is(err, ErrInvalidInput), errors.As(err, &parseErr), errors.As(err, &syntaxErr):
+		return EXIT_INVALID, "invalid_input"
+	case errors.Is(err, solver.ErrNoSolutionsExist):
+		return EXIT_NO_SOLUTION, "no_solution"
+	case errors.Is(err, ErrPartialBatch):
+		return EXIT_PARTIAL, "partial"
+	default:
+		return EXIT_FAILURE, "failure"
+	}
+}
+
+// reportError writes the error of a failed command to stderr and returns
+// the exit code.
+func reportError(command string, err error, format string) int {
+	code, kind := exitCode(err)
+	if format == ERROR_FORMAT_JSON {
+		json.NewEncoder(os.Stderr).Encode(struct {
+			Command string `json:"command,omitempty"`
+			Error   string `json:"error"`
+			Kind    string `json:"kind"`
+			Code    int    `json:"exit_code"`
+		}{command, err.Error(), kind, code})
+		return code
+	}
+	if command == "" {
+		logger.Error("poweq failed", "error", err, "exit_code", code)
+	} else {
+		logger.Error("Command failed", "command", command, "error", err, "exit_code", code)
+	}
+	return code
+}
+
+// setupLogging applies the log flags over the configuration, then sends
+// the logs of the CLI and of the solver to stderr.
+func setupLogging() error {
+	if *logLevel != "" {
+		conf.Set("log.level", *logLevel, config.SOURCE_FLAG+" -log-level")
+	}
+	if *logFormat != "" {
+		conf.Set("log.format", *logFormat, config.SOURCE_FLAG+" -log-format")
+	}
+	handler, err := conf.Log.Handler(os.Stderr)
+	if err != nil {
+		return err
+	}
+	logger = slog.New(handler)
+	solver.SetLogHandler(handler)
+	return nil
+}
+
+// command is a subcommand of poweq.
+type command struct {
+	Name    string
+	Args    string // Positional arguments, for the usage line
+	Summary string
+	// Flags declares the flags of the command, nil if it has none. Run
+	// parses the same declaration, help and completion list it.
+	Flags func(flagSet *flag.FlagSet)
+	Run   func(args []string) error
+}
+
+// commandFlags is the flags type of a command, declaring its fields as
+// flags.
+type commandFlags[F any] interface {
+	*F
+	declare(flagSet *flag.FlagSet)
+}
+
+// declareFlags is the Flags of a command whose flags type is F.
+func declareFlags[F any, P commandFlags[F]](flagSet *flag.FlagSet) {
+	P(new(F)).declare(flagSet)
+}
+
+// commands is filled in init, as help refers back to it.
+var commands []command
+
+func init() {
+	globalFlags.Usage = func() { writeUsage(globalFlags.Output()) }
+	commands = []command{
+		{"solve", "", "Solve one equation from the flags, or every job of a file", declareFlags[solveFlags], solveCommand},
+		{"scan", "", "Solve a jobs file in batch, with checkpoints and a summary report", declareFlags[scanFlags], func(args []string) error {
+			_, err := scanCommand(args)
+			return err
+		}},
+		{"generate", "", "Write random or stress jobs to a file", declareFlags[generateFlags], generateCommand},
+		{"bench", "", "Compare the speed and accuracy of the solving methods", declareFlags[benchFlags], benchCommand},
+		{"check", "", "Check the methods against the closed-form roots of stress jobs", declareFlags[checkFlags], checkCommand},
+		{"diff", "old new", "Compare two results files, failing on regressions", declareFlags[diffFlags], diffCommand},
+		{"explain", "", "Show the analysis of a job: log form, x_limit, expected roots, initial guesses and closed form", declareFlags[explainFlags], explainCommand},
+		{"plot", "", "Draw f(x) with its roots, x_limit and initial guesses, in ASCII or SVG", declareFlags[plotFlags], plotCommand},
+		{"repl", "", "Explore an equation interactively: set parameters, solve, analyze and plot", declareFlags[replFlags], replCommand},
+		{"config", "show", "Print the effective configuration and its sources", declareFlags[configFlags], configCommand},
+		{"completion", "bash | zsh | fish", "Print a shell completion script for the commands and their flags", nil, completionCommand},
+		{"help", "[command | all]", "Show this help, the flags of a command, or of all of them", nil, helpCommand},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	i := slices.IndexFunc(commands, func(c command) bool { return c.Name == name })
+	if i < 0 {
+		return command{}, false
+	}
+	return commands[i], true
+}
+
+// usageOutput receives the usage of the commands, stdout for help.
+var usageOutput io.Writer = os.Stderr
+
+// newFlagSet creates the flags of a command. Parse errors are returned,
+// not fatal, so that they get the exit code and format of other errors.
+func newFlagSet(name string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.SetOutput(usageOutput)
+	flagSet.Usage = func() {
+		cmd, _ := findCommand(name)
+		line := strings.TrimSpace("poweq " + name + " [flags] " + cmd.Args)
+		fmt.Fprintf(flagSet.Output(), "Usage: %s\n\n%s.\n\nFlags:\n", line, cmd.Summary)
+		flagSet.PrintDefaults()
+	}
+	return flagSet
+}
+
+// flagSet declares the flags of a command on a new flag set, without
+// parsing them.
+func (cmd command) flagSet() *flag.FlagSet {
+	flagSet := newFlagSet(cmd.Name)
+	if cmd.Flags != nil {
+		cmd.Flags(flagSet)
+	}
+	return flagSet
+}
+
+// parseFlags parses args, tagging errors as usage errors. -h returns
+// flag.ErrHelp, which is not a failure.
+func parseFlags(flagSet *flag.FlagSet, args []string) error {
+	err := flagSet.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return tagged(ErrUsage, err)
+}
+
+// writeUsage lists the commands and the exit codes.
+func writeUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: poweq [global flags] <command> [flags]")
+	fmt.Fprintln(w, "\nSolves x^n = K m^x.\n\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Name, cmd.Summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun 'poweq help <command>' or 'poweq <command> -h' for the flags of a command.")
+	fmt.Fprintln(w, "\nGlobal flags:")
+	globalFlags.SetOutput(w)
+	globalFlags.PrintDefaults()
+	fmt.Fprintln(w, "\nExit codes:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, line := range []string{
+		"0\tSuccess",
+		"1\tFailure, or a regression found by diff",
+		"2\tUsage error",
+		"3\tInvalid job, input file or configuration",
+		"4\tThe equation has no solution",
+		"5\tBatch completed, but rows were rejected",
+		"6\tA file could not be read or written",
+	} {
+		fmt.Fprintf(tw, "  %s\n", line)
+	}
+	tw.Flush()
+}
+
+var errorFormats = []string{ERROR_FORMAT_TEXT, ERROR_FORMAT_JSON}
+
+// parseGlobalFlags parses the flags before the command and returns the
+// command line of the command.
+func parseGlobalFlags(args []string) ([]string, error) {
+	if err := parseFlags(globalFlags, args); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(errorFormats, *errorFormat) {
+		format := *errorFormat
+		*errorFormat = ERROR_FORMAT_TEXT
+		return nil, usageErrorf("unknown error format %q, expected %s", format, strings.Join(errorFormats, " or "))
+	}
+	return globalFlags.Args(), nil
+}
@@ -1,199 +1,950 @@
 package main
 
 import (
-	"encoding/csv"
+	"cmp"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"iter"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/peterh/liner"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/plot"
 	"github.com/AbdallahZerfaoui/poweq/solver"
 )
 
+// Built-in defaults, the config file and environment may change them
 const (
-	DEFAULT_SOLUTIONS_ALGO = "auto"
-	DEFAULT_ERROR_SOLUTION = -1.0
+	DEFAULT_SOLUTIONS_ALGO = config.DEFAULT_ALGORITHM
+	DEFAULT_TOL            = config.DEFAULT_TOL
+	DEFAULT_MAX_ITER       = config.DEFAULT_MAX_ITER
 )
 
-// logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+// solveFlags are the flags of solve.
+type solveFlags struct {
+	n, m, K, a, b, tolence float64
+	maxIter, precision     int
+	algorithm, criterion   string
+	timeout                time.Duration
+
+	in, out                     string
+	format, inFormat, outFormat string
+	digits                      int
+}
+
+func (f *solveFlags) declare(fs *flag.FlagSet) {
+	fs.Float64Var(&f.n, "n", 1.0, "The exponent n in the equation x^n = K m^x")
+	fs.Float64Var(&f.m, "m", 2.718281828, "The base m in the equation x^n = K m^x")
+	fs.Float64Var(&f.K, "K", 1.0, "The coefficient K in the equation x^n = K m^x")
+	fs.Float64Var(&f.a, "a", 1e-6, "Lowwer bound of the interval to search for a solution")
+	fs.Float64Var(&f.b, "b", 1e6, "Upper bound of the interval to search for a solution")
+	fs.Float64Var(&f.tolence, "tol", conf.Solver.Tolerance, "Tolerance for the solution")
+	fs.IntVar(&f.maxIter, "maxIter", conf.Solver.MaxIter, "Maximum number of iterations")
+	fs.StringVar(&f.algorithm, "alg", conf.Solver.Algorithm, "Algorithm to use: 'newton', 'bisection' or 'auto'")
+	fs.IntVar(&f.precision, "precision", conf.Solver.Precision, "Significant digits required on x, makes the step test relative (0 to use -tol)")
+	fs.StringVar(&f.criterion, "criterion", conf.Solver.Criterion, "Stopping criterion: 'step', 'residual', 'both' or 'any' (default: method's own)")
+	fs.DurationVar(&f.timeout, "timeout", conf.Solver.Timeout, "Time limit for solving, e.g. 500ms (0 for none)")
 
-func solveCommand(args []string) ([]solver.Result, error) {
-	solverFlagSet := flag.NewFlagSet("poweq", flag.ExitOnError)
+	// Input and output
+	fs.StringVar(&f.in, "in", "", "Read jobs from this file instead of the flags above ('-' for stdin)")
+	fs.StringVar(&f.out, "out", STDIO, "Output file for the solutions ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Format of input and output: csv, json, ndjson, arrow, parquet or table (output only), default from the file extension")
+	fs.StringVar(&f.inFormat, "in-format", "", "Format of the input, overrides -format")
+	fs.StringVar(&f.outFormat, "out-format", "", "Format of the output, overrides -format (default table)")
+	fs.IntVar(&f.digits, "digits", conf.Output.Digits, "Significant digits of the roots (0 for the shortest exact form)")
+	addConfigFlag(fs)
+}
 
-	// Create flag set for the "solve" command
-	n := solverFlagSet.Float64("n", 1.0, "The exponent n in the equation x^n = K m^x")
-	m := solverFlagSet.Float64("m", 2.718281828, "The base m in the equation x^n = K m^x")
-	K := solverFlagSet.Float64("K", 1.0, "The coefficient K in the equation x^n = K m^x")
-	a := solverFlagSet.Float64("a", 1e-6, "Lowwer bound of the interval to search for a solution")
-	b := solverFlagSet.Float64("b", 1e6, "Upper bound of the interval to search for a solution")
-	tolence := solverFlagSet.Float64("tol", 1e-6, "Tolerance for the solution")
-	maxIter := solverFlagSet.Int("maxIter", 100, "Maximum number of iterations")
-	algorithm := solverFlagSet.String("alg", "auto", "Algorithm to use: 'newton' or 'bisection'")
+func solveCommand(args []string) error {
+	var opts solveFlags
+	solverFlagSet := newFlagSet("solve")
+	opts.declare(solverFlagSet)
 
 	// Parse flags and execute solving logic
-	err := solverFlagSet.Parse(args)
+	err := parseFlags(solverFlagSet, args)
 	if err != nil {
-		logger.Println("Error parsing flags", "error", err)
-		return nil, err
+		return err
 	}
 
-	newJob := solver.Job{Id: 0, N: *n, M: *m, K: *K,
-		A: *a, B: *b,
-		Tol: *tolence, MaxIter: *maxIter}
+	if !solver.IsMethod(opts.algorithm) {
+		return usageErrorf("unknown algorithm %q", opts.algorithm)
+	}
+	if opts.outFormat, err = resolveFormat(pickFormat(opts.outFormat, opts.format), opts.out, outputFallback(FORMAT_TABLE), jobfile.OutputFormats); err != nil {
+		return tagged(ErrUsage, err)
+	}
+	if err := solver.CheckDigits(opts.digits); err != nil {
+		return tagged(ErrUsage, err)
+	}
 
-	if err := newJob.Validate(); err != nil {
-		logger.Println("Invalid job parameters", "error", err)
-		return nil, err
+	var records iter.Seq[jobfile.Record]
+	var reader jobfile.Reader
+	if opts.in != "" {
+		// Flags only provide defaults for the optional fields of each job
+		if opts.inFormat, err = resolveFormat(pickFormat(opts.inFormat, opts.format), opts.in, FORMAT_CSV, jobfile.InputFormats); err != nil {
+			return tagged(ErrUsage, err)
+		}
+		inFile, err := openInput(opts.in)
+		if err != nil {
+			logger.Error("Error opening input file", "error", err)
+			return err
+		}
+		defer inFile.Close()
+		defaults := jobfile.Defaults{
+			Tol: opts.tolence, MaxIter: opts.maxIter, Algorithm: opts.algorithm,
+			Precision: opts.precision, Criterion: opts.criterion, Timeout: opts.timeout,
+		}
+		if reader, err = jobfile.NewReader(inFile, opts.inFormat, jobfile.Options{Defaults: defaults}); err != nil {
+			logger.Error("Error reading jobs from input file", "error", err)
+			return tagged(ErrInvalidInput, err)
+		}
+		records = reader.Records()
+	} else {
+		newJob := solver.Job{Id: 0, N: opts.n, M: opts.m, K: opts.K,
+			A: opts.a, B: opts.b,
+			Tol: opts.tolence, MaxIter: opts.maxIter,
+			Precision: opts.precision, Criterion: opts.criterion, Timeout: opts.timeout}
+
+		if err := newJob.Validate(); err != nil {
+			logger.Warn("Invalid job parameters", "error", err)
+			return tagged(ErrInvalidInput, err)
+		}
+		// Use the right solver functions from the solver package
+
+		logger.Info("Solving x^n = K m^x", "n", opts.n, "m", opts.m, "K", opts.K)
+		logger.Info("Searching for a solution", "a", opts.a, "b", opts.b, "tolerance", opts.tolence, "max_iterations", opts.maxIter)
+
+		records = func(yield func(jobfile.Record) bool) {
+			yield(jobfile.Record{Job: newJob, Algorithm: opts.algorithm, RawId: "0"})
+		}
 	}
-	// Use the right solver functions from the solver package
 
-	logger.Println("Solving the equation", "equation", fmt.Sprintf("x^%.2f = %.2f * %.2f^x", *n, *K, *m))
-	logger.Println("Searching for a solution", "interval", fmt.Sprintf("[%.2f, %.2f]", *a, *b), "tolerance", *tolence, "max iterations", *maxIter)
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			logger.Error("Error creating output file", "error", err)
+			return err
+		}
+		defer outFile.Close()
+	}
+	var extraNames []string
+	if reader != nil {
+		extraNames = reader.ExtraColumns()
+	}
+	writer := jobfile.NewWriter(outFile, opts.outFormat, jobfile.Options{Digits: opts.digits}, extraNames, 0)
+	if err := writer.WriteHeader(); err != nil {
+		return err
+	}
 
-	if !newJob.SolutionsExist() {
-		logger.Println("No solutions exist for the given parameters")
-		return nil, errors.New("no solutions exist for the given parameters")
+	var solveErr error
+	rejected := 0
+	for rec := range records {
+		if rec.Err != nil {
+			rejected++
+			logger.Warn("Rejected record", "line", rec.Line, "error", rec.Err)
+			if err := writer.WriteRejected(rec); err != nil {
+				return err
+			}
+			continue
+		}
+		results := rec.Job.Process(rec.Algorithm)
+		if err := writer.Write(rec, results); err != nil {
+			return err
+		}
+		if opts.in == "" && errors.Is(results[0].Err, solver.ErrNoSolutionsExist) {
+			logger.Warn("No solutions exist for the given parameters")
+			solveErr = solver.ErrNoSolutionsExist
+		}
+	}
+	if err := writer.Close(); err != nil {
+		logger.Error("Error writing solutions", "error", err)
+		return err
+	}
+	if reader != nil && reader.Err() != nil {
+		logger.Error("Error reading jobs from input file", "error", reader.Err())
+		return reader.Err()
 	}
+	if rejected > 0 {
+		return fmt.Errorf("%d rows rejected: %w", rejected, ErrPartialBatch)
+	}
+	return solveErr
+}
 
-	solutions := newJob.Solve(*algorithm, logger)
+// scanFlags are the flags of scan.
+type scanFlags struct {
+	in, out, rejectsOut, reportOut string
+	format, inFormat, outFormat    string
+	delim, comment                 string
+	strictColumns, strict, resume  bool
+	maxRejects, workers            int
+	checkpoint, digits             int
+	watch, cache                   bool
+	interval                       time.Duration
+	cacheDir                       string
 
-	return solutions, nil
+	// Defaults for the optional columns
+	algorithm, criterion string
+	tolerance            float64
+	maxIter, precision   int
+	timeout              time.Duration
 }
 
-func displaySolutions(solutions []solver.Result) {
-	for _, result := range solutions {
-		if result.Err != nil {
-			logger.Println("Error", "error", result.Err)
-		} else {
-			logger.Println("Found solution", "x", result.X, "steps", result.Steps)
-		}
-	}
+func (f *scanFlags) declare(fs *flag.FlagSet) {
+	fs.StringVar(&f.in, "in", "jobs.csv", "Input file containing jobs to solve ('-' for stdin)")
+	fs.StringVar(&f.out, "out", "solutions.csv", "Output file to write solutions ('-' for stdout)")
+	fs.StringVar(&f.delim, "delim", ",", "Field delimiter of the input and output files ('tab' for TSV)")
+	fs.StringVar(&f.comment, "comment", "#", "Lines starting with this character are ignored (empty to disable)")
+	fs.BoolVar(&f.strictColumns, "strict-columns", false, "Fail on unknown columns instead of copying them to the output")
+	fs.StringVar(&f.rejectsOut, "rejects", conf.Scan.Rejects, "Output file listing rejected rows (empty to disable)")
+	fs.BoolVar(&f.strict, "strict", false, "Abort on the first rejected row")
+	fs.IntVar(&f.maxRejects, "max-rejects", 0, "Abort once more than this many rows are rejected (0 for no limit)")
+	fs.IntVar(&f.workers, "workers", conf.Scan.Workers, "Number of jobs solved in parallel")
+	fs.IntVar(&f.checkpoint, "checkpoint", conf.Scan.Checkpoint, "Flush results and save progress every this many rows (0 to disable)")
+	fs.BoolVar(&f.resume, "resume", false, "Resume from the last checkpoint, appending to the existing output")
+	fs.StringVar(&f.format, "format", "", "Format of input and output: csv, json, ndjson, arrow, parquet or table (output only), default from the file extension")
+	fs.StringVar(&f.inFormat, "in-format", "", "Format of the input, overrides -format")
+	fs.StringVar(&f.outFormat, "out-format", "", "Format of the output, overrides -format")
+	fs.IntVar(&f.digits, "digits", conf.Output.Digits, "Significant digits of the roots (0 for the shortest exact form)")
+	fs.StringVar(&f.reportOut, "report", "", "Also write the summary report as JSON to this file")
+	fs.BoolVar(&f.watch, "watch", false, "Keep running, solving again the rows that change whenever the input file does")
+	fs.DurationVar(&f.interval, "interval", DEFAULT_WATCH_INTERVAL, "How often -watch checks the input file")
+	fs.BoolVar(&f.cache, "cache", conf.Cache.Enabled, "Reuse the results of jobs already solved with the same parameters")
+	fs.StringVar(&f.cacheDir, "cache-dir", conf.Cache.Dir, "Directory also storing the results on disk, to reuse them across runs (empty for memory only)")
+	addConfigFlag(fs)
+
+	// Defaults for the optional columns, rows may override them
+	fs.StringVar(&f.algorithm, "alg", conf.Solver.Algorithm, "Default algorithm: 'newton', 'bisection' or 'auto'")
+	fs.Float64Var(&f.tolerance, "tol", conf.Solver.Tolerance, "Default tolerance")
+	fs.IntVar(&f.maxIter, "maxIter", conf.Solver.MaxIter, "Default maximum number of iterations")
+	fs.IntVar(&f.precision, "precision", conf.Solver.Precision, "Default significant digits required on x (0 to use the tolerance)")
+	fs.StringVar(&f.criterion, "criterion", conf.Solver.Criterion, "Default stopping criterion: 'step', 'residual', 'both' or 'any'")
+	fs.DurationVar(&f.timeout, "timeout", conf.Solver.Timeout, "Default time limit per job, e.g. 500ms (0 for none)")
 }
 
 func scanCommand(args []string) (solver.Batch, error) {
-	scannerFlagSet := flag.NewFlagSet("scan", flag.ExitOnError)
-
-	// Create flag set for the "scan" command
-	in := scannerFlagSet.String("in", "jobs.csv", "Input file containing jobs to solve")
-	out := scannerFlagSet.String("out", "solutions.csv", "Output file to write solutions")
+	var opts scanFlags
+	scannerFlagSet := newFlagSet("scan")
+	opts.declare(scannerFlagSet)
 
 	// Parse flags
-	err := scannerFlagSet.Parse(args)
+	err := parseFlags(scannerFlagSet, args)
 	if err != nil {
-		logger.Println("Error parsing flags", "error", err)
 		return solver.Batch{}, err
 	}
 
-	// Create a Batch instance
-	batch := solver.Batch{InFile: *in, OutFile: *out,
-		Jobs: []solver.Job{}, Results: []solver.Result{}}
+	if !solver.IsMethod(opts.algorithm) {
+		return solver.Batch{}, usageErrorf("unknown algorithm %q", opts.algorithm)
+	}
+	if !solver.IsCriterion(opts.criterion) {
+		return solver.Batch{}, usageErrorf("unknown stopping criterion %q", opts.criterion)
+	}
+	if err := solver.CheckDigits(opts.digits); err != nil {
+		return solver.Batch{}, tagged(ErrUsage, err)
+	}
 
-	// Open files
-	inFile, err := os.Open(*in)
-	if err != nil {
-		logger.Println("Error opening input file", "error", err)
+	cfg := scanConfig{
+		InFile: opts.in, OutFile: opts.out, RejectsOut: opts.rejectsOut,
+		CSV: jobfile.Options{StrictColumns: opts.strictColumns, Digits: opts.digits, Defaults: jobfile.Defaults{
+			Tol: opts.tolerance, MaxIter: opts.maxIter, Algorithm: opts.algorithm,
+			Precision: opts.precision, Criterion: opts.criterion, Timeout: opts.timeout,
+		}},
+		Strict: opts.strict, MaxRejects: opts.maxRejects,
+		Workers: opts.workers, Checkpoint: opts.checkpoint, Resume: opts.resume,
+		ReportOut: opts.reportOut,
+	}
+	if cfg.InFormat, err = resolveFormat(pickFormat(opts.inFormat, opts.format), opts.in, FORMAT_CSV, jobfile.InputFormats); err != nil {
+		return solver.Batch{}, tagged(ErrUsage, err)
+	}
+	if cfg.OutFormat, err = resolveFormat(pickFormat(opts.outFormat, opts.format), opts.out, outputFallback(FORMAT_CSV), jobfile.OutputFormats); err != nil {
+		return solver.Batch{}, tagged(ErrUsage, err)
+	}
+	if cfg.CSV.Delimiter, err = jobfile.ParseRune("delim", opts.delim); err != nil {
+		return solver.Batch{}, tagged(ErrUsage, err)
+	}
+	if cfg.CSV.Comment, err = jobfile.ParseRune("comment", opts.comment); err != nil {
+		return solver.Batch{}, tagged(ErrUsage, err)
+	}
+
+	cacheConf := conf.Cache
+	cacheConf.Enabled, cacheConf.Dir = opts.cache, opts.cacheDir
+	if cfg.Cache, err = cacheConf.Open(); err != nil {
 		return solver.Batch{}, err
 	}
-	defer inFile.Close()
 
-	outFile, err := os.Create(*out)
+	if opts.watch {
+		switch {
+		case cfg.InFile == STDIO || cfg.OutFile == STDIO:
+			return solver.Batch{}, usageErrorf("cannot watch a scan reading stdin or writing stdout")
+		case cfg.Resume:
+			return solver.Batch{}, usageErrorf("-watch and -resume cannot be combined")
+		case opts.interval <= 0:
+			return solver.Batch{}, usageErrorf("-interval must be positive")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return solver.Batch{}, watchScan(ctx, cfg, opts.interval)
+	}
+
+	batch, err := runScan(cfg)
+	if cfg.Cache != nil {
+		stats := cfg.Cache.Stats()
+		logger.Info("Result cache", "hits", stats.Hits, "misses", stats.Misses)
+	}
+	if err == nil && batch.NbRejected > 0 {
+		err = fmt.Errorf("%d rows rejected: %w", batch.NbRejected, ErrPartialBatch)
+	}
+	return batch, err
+}
+
+// generateFlags are the flags of generate.
+type generateFlags struct {
+	N                   int
+	out, format         string
+	seed                int64
+	mode, families, mix string
+	dists               []string // Distribution of each parameter of paramFlags
+}
+
+func (f *generateFlags) declare(fs *flag.FlagSet) {
+	fs.IntVar(&f.N, "N", 50, "Number of jobs to generate")
+	fs.StringVar(&f.out, "out", "jobs.csv", "Output file to write jobs ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Format of the output: csv, json, ndjson, arrow or parquet, default from the file extension")
+	fs.Int64Var(&f.seed, "seed", 0, "Seed of the random draws, the same seed gives the same jobs (0 for a random one)")
+	fs.StringVar(&f.mode, "mode", MODE_RANDOM, "Generation mode: 'random' or 'stress' (edge-case families with reference roots)")
+	fs.StringVar(&f.families, "families", "", "Comma separated stress families (default: all): "+strings.Join(familyNames(), ", "))
+	fs.StringVar(&f.mix, "mix", "", "Share of jobs by number of roots in [a, b], e.g. two=0.3,tangent=0.2,none=0.1, the rest has at least one root")
+	addConfigFlag(fs)
+
+	// Distributions of the parameters: uniform:min:max, loguniform:min:max or fixed:value
+	cfg := defaultGenConfig()
+	params := cfg.paramFlags()
+	f.dists = make([]string, len(params))
+	for i, param := range params {
+		fs.StringVar(&f.dists[i], param.name, param.dst.String(), "Distribution of "+param.name)
+	}
+}
+
+func generateCommand(args []string) error {
+	var opts generateFlags
+	generateFlagSet := newFlagSet("generate")
+	opts.declare(generateFlagSet)
+
+	err := parseFlags(generateFlagSet, args)
 	if err != nil {
-		logger.Println("Error creating output file", "error", err)
-		return solver.Batch{}, err
+		return err
 	}
-	defer outFile.Close()
 
-	// Read jobs from input file
-	jobs, err := readJobsFromCSV(inFile)
+	cfg := defaultGenConfig()
+	for i, param := range cfg.paramFlags() {
+		if *param.dst, err = parseDist(param.name, opts.dists[i]); err != nil {
+			return tagged(ErrUsage, err)
+		}
+	}
+	if opts.mix != "" {
+		if cfg.Mix, err = parseMix(opts.mix); err != nil {
+			return tagged(ErrUsage, err)
+		}
+	}
+	if opts.format, err = resolveFormat(opts.format, opts.out, FORMAT_CSV, jobfile.InputFormats); err != nil {
+		return tagged(ErrUsage, err)
+	}
+	// A random seed is still logged, so that the run can be replayed
+	if opts.seed == 0 {
+		opts.seed = rand.Int63()
+	}
+	logger.Info("Generating jobs", "seed", opts.seed)
+
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+
+	redraws := 0
+	rng := rand.New(rand.NewSource(opts.seed))
+	var written int
+	switch opts.mode {
+	case MODE_STRESS:
+		// Distributions and mix do not apply, each family draws its own
+		stress, err := parseFamilies(opts.families)
+		if err != nil {
+			return tagged(ErrUsage, err)
+		}
+		written, err = writeJobs(outFile, opts.format, stressJobs(rng, stress, opts.N), true)
+		if err != nil {
+			return err
+		}
+	case MODE_RANDOM:
+		written, err = writeJobs(outFile, opts.format, plainJobs(generateJobs(rng, cfg, opts.N, &redraws)), false)
+		if err != nil {
+			return err
+		}
+		logger.Info("Jobs drawn again to fit their class", "redraws", redraws)
+	default:
+		return usageErrorf("unknown mode %q", opts.mode)
+	}
+
+	fmt.Fprintf(os.Stderr, "Generated %d jobs into %s\n", written, opts.out)
+	return nil
+}
+
+// benchFlags are the flags of bench.
+type benchFlags struct {
+	in, inFormat string
+	N            int
+	seed         int64
+	methods      string
+	runs         int
+	relTol       float64
+	out, format  string
+}
+
+func (f *benchFlags) declare(fs *flag.FlagSet) {
+	fs.StringVar(&f.in, "in", "", "Jobs file to benchmark on ('-' for stdin), default a generated set")
+	fs.StringVar(&f.inFormat, "in-format", "", "Format of the input: csv, json, ndjson, arrow or parquet, default from the file extension")
+	fs.IntVar(&f.N, "N", 1000, "Number of jobs to generate when there is no input file")
+	fs.Int64Var(&f.seed, "seed", 1, "Seed of the generated jobs, the same seed gives the same jobs")
+	fs.StringVar(&f.methods, "methods", strings.Join(solver.Methods, ","), "Comma separated algorithms to compare")
+	fs.IntVar(&f.runs, "runs", 1, "Number of times each method solves the whole set")
+	fs.Float64Var(&f.relTol, "rel-tol", 1e-6, "Relative distance under which a root matches the reference")
+	fs.StringVar(&f.out, "out", STDIO, "Output file for the comparison ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Output format: table or csv, default from the file extension, else table")
+	addConfigFlag(fs)
+}
+
+func benchCommand(args []string) error {
+	var opts benchFlags
+	benchFlagSet := newFlagSet("bench")
+	opts.declare(benchFlagSet)
+
+	err := parseFlags(benchFlagSet, args)
 	if err != nil {
-		logger.Println("Error reading jobs from input file", "error", err)
-		return solver.Batch{}, err
+		return err
 	}
-	batch.Jobs = jobs
-	// Build a map of jobs by their IDs for easy lookup
-	jobsMap := buildJobsMap(jobs)
 
-	// fmt.Println("[debug] Jobs loaded:", len(batch.Jobs))
-	// Solve each job and collect results
-	for _, job := range batch.Jobs {
-		if err := job.Validate(); err != nil {
-			logger.Println("Invalid job parameters", "error", err)
-			batch.Results = append(batch.Results, solver.Result{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: err})
-			continue
+	cfg := benchConfig{Methods: strings.Split(opts.methods, ","), Runs: opts.runs, RelTol: opts.relTol}
+	for _, method := range cfg.Methods {
+		if !solver.IsMethod(method) {
+			return usageErrorf("unknown algorithm %q", method)
 		}
-		if !job.SolutionsExist() {
-			batch.Results = append(batch.Results, solver.Result{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: errors.New("no solutions exist for the given parameters")})
-			continue
+	}
+	if cfg.Runs < 1 {
+		return usageErrorf("runs must be at least 1")
+	}
+	if opts.format, err = resolveFormat(opts.format, opts.out, FORMAT_TABLE, []string{FORMAT_TABLE, FORMAT_CSV}); err != nil {
+		return tagged(ErrUsage, err)
+	}
+
+	// Every method solves the same jobs, so they are all kept in memory
+	var jobs []solver.Job
+	if opts.in == "" {
+		for job, err := range generateJobs(rand.New(rand.NewSource(opts.seed)), defaultGenConfig(), opts.N, nil) {
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+	} else {
+		if opts.inFormat, err = resolveFormat(opts.inFormat, opts.in, FORMAT_CSV, jobfile.InputFormats); err != nil {
+			return tagged(ErrUsage, err)
 		}
-		solutions := job.Solve(DEFAULT_SOLUTIONS_ALGO, logger) // or "bisection"
-		if len(solutions) > 0 {
-			batch.Results = append(batch.Results, solutions...)
-		} else {
-			batch.Results = append(batch.Results, solver.Result{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: errors.New("no solutions found")})
+		inFile, err := openInput(opts.in)
+		if err != nil {
+			return err
+		}
+		defer inFile.Close()
+		defaults := solverDefaults()
+		reader, err := jobfile.NewReader(inFile, opts.inFormat, jobfile.Options{Defaults: defaults})
+		if err != nil {
+			return tagged(ErrInvalidInput, err)
+		}
+		for rec := range reader.Records() {
+			if rec.Err != nil {
+				logger.Warn("Skipping rejected record", "line", rec.Line, "error", rec.Err)
+				continue
+			}
+			jobs = append(jobs, rec.Job)
+		}
+		if err := reader.Err(); err != nil {
+			return err
 		}
 	}
+	logger.Info("Benchmarking", "jobs", len(jobs), "methods", cfg.Methods, "runs", cfg.Runs)
+
+	all := runBench(jobs, cfg)
 
-	// Write results to output file using the helper function
-	batch, err = writeResultsToCSV(outFile, batch, jobsMap)
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+	if opts.format == FORMAT_CSV {
+		return writeBenchCSV(outFile, all)
+	}
+	return writeBenchTable(outFile, all)
+}
+
+// checkFlags are the flags of check.
+type checkFlags struct {
+	in, inFormat      string
+	N                 int
+	seed              int64
+	families, methods string
+	relTol            float64
+	out, format       string
+}
+
+func (f *checkFlags) declare(fs *flag.FlagSet) {
+	fs.StringVar(&f.in, "in", "", "Jobs file, as written by generate -mode stress ('-' for stdin), default a generated stress set")
+	fs.StringVar(&f.inFormat, "in-format", "", "Format of the input: csv, json, ndjson, arrow or parquet, default from the file extension")
+	fs.IntVar(&f.N, "N", 600, "Number of stress jobs to generate when there is no input file")
+	fs.Int64Var(&f.seed, "seed", 1, "Seed of the generated jobs")
+	fs.StringVar(&f.families, "families", "", "Comma separated stress families to generate (default: all)")
+	fs.StringVar(&f.methods, "methods", strings.Join(solver.Methods, ","), "Comma separated algorithms to check")
+	fs.Float64Var(&f.relTol, "rel-tol", 1e-6, "Relative distance under which a root matches the reference")
+	fs.StringVar(&f.out, "out", STDIO, "Output file for the report ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Output format: table or csv, default from the file extension, else table")
+	addConfigFlag(fs)
+}
+
+func checkCommand(args []string) error {
+	var opts checkFlags
+	checkFlagSet := newFlagSet("check")
+	opts.declare(checkFlagSet)
+
+	err := parseFlags(checkFlagSet, args)
 	if err != nil {
-		logger.Println("Error writing results to output file", "error", err)
-		return batch, err
+		return err
+	}
+
+	checked := strings.Split(opts.methods, ",")
+	for _, method := range checked {
+		if !solver.IsMethod(method) {
+			return usageErrorf("unknown algorithm %q", method)
+		}
 	}
-	return batch, nil
+	if opts.format, err = resolveFormat(opts.format, opts.out, FORMAT_TABLE, []string{FORMAT_TABLE, FORMAT_CSV}); err != nil {
+		return tagged(ErrUsage, err)
+	}
+
+	var jobs iter.Seq2[genJob, error]
+	if opts.in == "" {
+		stress, err := parseFamilies(opts.families)
+		if err != nil {
+			return tagged(ErrUsage, err)
+		}
+		jobs = stressJobs(rand.New(rand.NewSource(opts.seed)), stress, opts.N)
+	} else {
+		if opts.inFormat, err = resolveFormat(opts.inFormat, opts.in, FORMAT_CSV, jobfile.InputFormats); err != nil {
+			return tagged(ErrUsage, err)
+		}
+		inFile, err := openInput(opts.in)
+		if err != nil {
+			return err
+		}
+		defer inFile.Close()
+		defaults := solverDefaults()
+		reader, err := jobfile.NewReader(inFile, opts.inFormat, jobfile.Options{Defaults: defaults})
+		if err != nil {
+			return tagged(ErrInvalidInput, err)
+		}
+		jobs = func(yield func(genJob, error) bool) {
+			for rec := range reader.Records() {
+				if rec.Err != nil {
+					logger.Warn("Skipping rejected record", "line", rec.Line, "error", rec.Err)
+					continue
+				}
+				job, err := labeled(rec)
+				if !yield(job, tagged(ErrInvalidInput, err)) || err != nil {
+					return
+				}
+			}
+			if err := reader.Err(); err != nil {
+				yield(genJob{}, err)
+			}
+		}
+	}
+
+	all, err := runCheck(jobs, checked, opts.relTol)
+	if err != nil {
+		return err
+	}
+
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+	if opts.format == FORMAT_CSV {
+		return writeCheckCSV(outFile, all)
+	}
+	return writeCheckTable(outFile, all)
 }
 
-func generateCommand(args []string) error {
-	generateFlagSet := flag.NewFlagSet("generate", flag.ExitOnError)
+// diffFlags are the flags of diff.
+type diffFlags struct {
+	relTol                       float64
+	strict                       bool
+	inFormat, delim, out, format string
+}
+
+func (f *diffFlags) declare(fs *flag.FlagSet) {
+	fs.Float64Var(&f.relTol, "rel-tol", 1e-6, "Relative distance under which a root is unchanged")
+	fs.BoolVar(&f.strict, "strict", false, "Also fail on roots that appeared and jobs that were added")
+	fs.StringVar(&f.inFormat, "in-format", "", "Format of both results files: csv, json, ndjson, arrow or parquet, default from the file extensions")
+	fs.StringVar(&f.delim, "delim", ",", "Field delimiter of CSV results ('tab' for TSV)")
+	fs.StringVar(&f.out, "out", STDIO, "Output file for the differences ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Output format: table or csv, default from the file extension, else table")
+	addConfigFlag(fs)
+}
 
-	N := generateFlagSet.Int("N", 50, "Number of jobs to generate")
-	out := generateFlagSet.String("out", "jobs.csv", "Output file to write jobs")
+// diffCommand compares two results files. It returns ErrRegression when
+// the new one regresses, for pipelines to fail on.
+func diffCommand(args []string) error {
+	var opts diffFlags
+	diffFlagSet := newFlagSet("diff")
+	opts.declare(diffFlagSet)
 
-	err := generateFlagSet.Parse(args)
+	err := parseFlags(diffFlagSet, args)
 	if err != nil {
 		return err
 	}
+	if diffFlagSet.NArg() != 2 {
+		diffFlagSet.Usage()
+		return usageErrorf("expected two results files")
+	}
+	if opts.format, err = resolveFormat(opts.format, opts.out, FORMAT_TABLE, []string{FORMAT_TABLE, FORMAT_CSV}); err != nil {
+		return tagged(ErrUsage, err)
+	}
+	var fileOpts jobfile.Options
+	if fileOpts.Delimiter, err = jobfile.ParseRune("delim", opts.delim); err != nil {
+		return tagged(ErrUsage, err)
+	}
+
+	var sides [2]*outcomes
+	for i, path := range diffFlagSet.Args() {
+		pathFormat, err := resolveFormat(opts.inFormat, path, FORMAT_CSV, jobfile.InputFormats)
+		if err != nil {
+			return tagged(ErrUsage, err)
+		}
+		file, err := openInput(path)
+		if err != nil {
+			return err
+		}
+		sides[i], err = readOutcomes(file, pathFormat, fileOpts)
+		file.Close()
+		if err != nil {
+			return tagged(ErrInvalidInput, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	cfg := diffConfig{RelTol: opts.relTol, Strict: opts.strict}
+	diffs := cfg.diffOutcomes(sides[0], sides[1])
 
-	outFile, err := os.Create(*out)
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			return err
+		}
+		defer outFile.Close()
+	}
+	if opts.format == FORMAT_CSV {
+		err = writeDiffCSV(outFile, diffs, cfg)
+	} else {
+		err = writeDiffTable(outFile, diffs, cfg)
+	}
 	if err != nil {
 		return err
 	}
-	defer outFile.Close()
-
-	writer := csv.NewWriter(outFile)
-	defer writer.Flush()
-
-	// Write header
-	writer.Write([]string{"Id", "N", "M", "K", "A", "B", "Tol", "MaxIter"})
-
-	for i := 1; i <= *N; i++ {
-		job := solver.Job{
-			Id:      i,
-			N:       float64(rand.Intn(1000)+10) / 100.0,   // n between 0.1 and 10.0
-			M:       float64(rand.Intn(500)+110) / 100.0,   // m between 1.1 and 6.0
-			K:       float64(rand.Intn(1000000)+1) / 100.0, // K between 0.01 and 10000
-			A:       1e-6,
-			B:       float64(rand.Intn(1000000) + 10), // b between 0.1 and 1000010
-			Tol:     1e-6,
-			MaxIter: rand.Intn(91) + 10, // MaxIter between 10 and 100
-		}
-		if job.SolutionsExist() { // TODO: Should i keep this check?
-			record := []string{
-				fmt.Sprintf("%d", job.Id),
-				fmt.Sprintf("%.2f", job.N),
-				fmt.Sprintf("%.2f", job.M),
-				fmt.Sprintf("%.2f", job.K),
-				fmt.Sprintf("%.2f", job.A),
-				fmt.Sprintf("%.2f", job.B),
-				fmt.Sprintf("%.2e", job.Tol),
-				fmt.Sprintf("%d", job.MaxIter),
-			}
-			writer.Write(record)
-		} else {
-			i-- // Retry this iteration
+
+	logger.Info("Diff completed", "old_jobs", len(sides[0].ids), "new_jobs", len(sides[1].ids), "differences", len(diffs), "by_kind", formatCounts(summarize(diffs)))
+	if slices.ContainsFunc(diffs, cfg.isRegression) {
+		return ErrRegression
+	}
+	return nil
+}
+
+// explainFlags are the flags of explain.
+type explainFlags struct {
+	n, m, K, a, b float64
+	format        string
+	digits        int
+}
+
+func (f *explainFlags) declare(fs *flag.FlagSet) {
+	fs.Float64Var(&f.n, "n", 1.0, "The exponent n in the equation x^n = K m^x")
+	fs.Float64Var(&f.m, "m", 2.718281828, "The base m in the equation x^n = K m^x")
+	fs.Float64Var(&f.K, "K", 1.0, "The coefficient K in the equation x^n = K m^x")
+	fs.Float64Var(&f.a, "a", 1e-6, "Lower bound of the interval searched for a solution")
+	fs.Float64Var(&f.b, "b", 1e6, "Upper bound of the interval searched for a solution")
+	fs.StringVar(&f.format, "format", FORMAT_TABLE, "Output format: table or json")
+	fs.IntVar(&f.digits, "digits", conf.Output.Digits, "Significant digits of the numbers of the table (0 for the shortest exact form)")
+	addConfigFlag(fs)
+}
+
+// explainCommand prints the analysis of one job, without solving it.
+func explainCommand(args []string) error {
+	var opts explainFlags
+	explainFlagSet := newFlagSet("explain")
+	opts.declare(explainFlagSet)
+
+	if err := parseFlags(explainFlagSet, args); err != nil {
+		return err
+	}
+	if err := solver.CheckDigits(opts.digits); err != nil {
+		return tagged(ErrUsage, err)
+	}
+	job := solver.Job{N: opts.n, M: opts.m, K: opts.K, A: opts.a, B: opts.b, Tol: conf.Solver.Tolerance, MaxIter: conf.Solver.MaxIter}
+	if err := job.Validate(); err != nil {
+		return tagged(ErrInvalidInput, err)
+	}
+
+	explanation := job.Explain()
+	switch opts.format {
+	case FORMAT_TABLE:
+		return writeExplanation(os.Stdout, job, explanation, opts.digits)
+	case FORMAT_JSON:
+		return writeExplanationJSON(os.Stdout, explanation)
+	default:
+		return usageErrorf("unsupported format %q, expected table or json", opts.format)
+	}
+}
+
+// plotFlags are the flags of plot.
+type plotFlags struct {
+	n, m, K, a, b, from, to float64
+	sides                   bool
+	scale, out, format      string
+	width, height           int
+}
+
+func (f *plotFlags) declare(fs *flag.FlagSet) {
+	fs.Float64Var(&f.n, "n", 1.0, "The exponent n in the equation x^n = K m^x")
+	fs.Float64Var(&f.m, "m", 2.718281828, "The base m in the equation x^n = K m^x")
+	fs.Float64Var(&f.K, "K", 1.0, "The coefficient K in the equation x^n = K m^x")
+	fs.Float64Var(&f.a, "a", 1e-6, "Lower bound of the interval searched for a solution")
+	fs.Float64Var(&f.b, "b", 1e6, "Upper bound of the interval searched for a solution")
+	fs.Float64Var(&f.from, "from", 0, "Left end of the plot (default a)")
+	fs.Float64Var(&f.to, "to", 0, "Right end of the plot (default b)")
+	fs.BoolVar(&f.sides, "sides", false, "Plot both sides, ln(x^n) and ln(K m^x), instead of f(x)")
+	fs.StringVar(&f.scale, "scale", plot.SCALE_AUTO, "Scale of x: auto, linear or log")
+	fs.StringVar(&f.out, "out", STDIO, "Output file ('-' for stdout)")
+	fs.StringVar(&f.format, "format", "", "Output format: ascii or svg, default from the file extension, else ascii")
+	fs.IntVar(&f.width, "width", 0, "Width in characters or pixels (default 72 for ascii, 800 for svg)")
+	fs.IntVar(&f.height, "height", 0, "Height in characters or pixels (default 20 for ascii, 480 for svg)")
+	addConfigFlag(fs)
+}
+
+// plotCommand draws f(x) for one job, in ASCII or SVG.
+func plotCommand(args []string) error {
+	var opts plotFlags
+	plotFlagSet := newFlagSet("plot")
+	opts.declare(plotFlagSet)
+
+	err := parseFlags(plotFlagSet, args)
+	if err != nil {
+		return err
+	}
+	if opts.format, err = resolveFormat(opts.format, opts.out, FORMAT_ASCII, []string{FORMAT_ASCII, FORMAT_SVG}); err != nil {
+		return tagged(ErrUsage, err)
+	}
+
+	job := solver.Job{N: opts.n, M: opts.m, K: opts.K, A: opts.a, B: opts.b, Tol: conf.Solver.Tolerance, MaxIter: conf.Solver.MaxIter}
+	if err := job.Validate(); err != nil {
+		return tagged(ErrInvalidInput, err)
+	}
+	// The plot covers [a, b] unless given a range
+	ranged := map[string]bool{}
+	plotFlagSet.Visit(func(f *flag.Flag) { ranged[f.Name] = true })
+	if !ranged["from"] {
+		opts.from = opts.a
+	}
+	if !ranged["to"] {
+		opts.to = opts.b
+	}
+	p, err := plot.New(job, opts.from, opts.to)
+	if err == nil {
+		err = p.SetScale(opts.scale)
+	}
+	if err != nil {
+		return tagged(ErrUsage, err)
+	}
+	p.Sides = opts.sides
+	if !job.SolutionsExist() {
+		logger.Info("No solutions exist, f(x) stays below 0", "x_limit", p.XLimit)
+	}
+
+	outFile := os.Stdout
+	if opts.out != STDIO {
+		if outFile, err = os.Create(opts.out); err != nil {
+			return err
 		}
 	}
+	if opts.format == FORMAT_SVG {
+		err = p.SVG(outFile, cmp.Or(opts.width, plot.SVG_WIDTH), cmp.Or(opts.height, plot.SVG_HEIGHT))
+	} else {
+		err = p.ASCII(outFile, cmp.Or(opts.width, plot.ASCII_WIDTH), cmp.Or(opts.height, plot.ASCII_HEIGHT))
+	}
+	if errors.Is(err, plot.ErrTooSmall) {
+		err = tagged(ErrUsage, err)
+	}
+	// A created file is closed once, and a failed close loses the plot
+	if outFile != os.Stdout {
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// configFlags are the flags of config.
+type configFlags struct {
+	format string
+}
+
+func (f *configFlags) declare(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "format", FORMAT_TABLE, "Output format: table or json")
+	addConfigFlag(fs)
+}
 
-	fmt.Printf("Generated %d jobs into %s\n", *N, *out)
+// configCommand prints the effective configuration and where each
+// setting comes from.
+func configCommand(args []string) error {
+	var opts configFlags
+	configFlagSet := newFlagSet("config")
+	opts.declare(configFlagSet)
+
+	subcommand := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+	if err := parseFlags(configFlagSet, args); err != nil {
+		return err
+	}
+	if subcommand != "show" {
+		configFlagSet.Usage()
+		return usageErrorf("expected the show subcommand")
+	}
+	if conf.File != "" {
+		logger.Info("Config file", "path", conf.File)
+	}
+	switch opts.format {
+	case FORMAT_TABLE:
+		return writeConfigTable(os.Stdout, conf)
+	case FORMAT_JSON:
+		return writeConfigJSON(os.Stdout, conf)
+	default:
+		return usageErrorf("unsupported format %q, expected table or json", opts.format)
+	}
+}
+
+// replFlags are the flags of repl.
+type replFlags struct {
+	history string
+}
+
+func (f *replFlags) declare(fs *flag.FlagSet) {
+	defaultHistory := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		defaultHistory = filepath.Join(home, REPL_HISTORY)
+	}
+	fs.StringVar(&f.history, "history", defaultHistory, "History file, empty to disable it")
+	addConfigFlag(fs)
+}
+
+// replCommand explores one equation interactively, with history and tab
+// completion in a terminal.
+func replCommand(args []string) error {
+	var opts replFlags
+	replFlagSet := newFlagSet("repl")
+	opts.declare(replFlagSet)
+	if err := parseFlags(replFlagSet, args); err != nil {
+		return err
+	}
+	if replFlagSet.NArg() > 0 {
+		return usageErrorf("unexpected arguments %v", replFlagSet.Args())
+	}
+
+	s := newSession(os.Stdout)
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(s.complete)
+	if opts.history != "" {
+		if file, err := os.Open(opts.history); err == nil {
+			line.ReadHistory(file)
+			file.Close()
+		}
+		defer func() {
+			file, err := os.Create(opts.history)
+			if err != nil {
+				logger.Warn("History not saved", "path", opts.history, "error", err)
+				return
+			}
+			defer file.Close()
+			line.WriteHistory(file)
+		}()
+	}
+	return s.run(line)
+}
+
+// completionCommand prints the completion script of a shell, generated
+// from the flags of the commands.
+func completionCommand(args []string) error {
+	completionFlagSet := newFlagSet("completion")
+	if err := parseFlags(completionFlagSet, args); err != nil {
+		return err
+	}
+	if completionFlagSet.NArg() != 1 {
+		completionFlagSet.Usage()
+		return usageErrorf("expected one shell: %s", strings.Join(shells, ", "))
+	}
+	return writeCompletion(os.Stdout, completionFlagSet.Arg(0))
+}
+
+// helpCommand prints the list of commands, the flags of one command, or
+// the flags of all of them.
+func helpCommand(args []string) error {
+	usageOutput = os.Stdout
+	defer func() { usageOutput = os.Stderr }()
+
+	if len(args) == 0 {
+		writeUsage(os.Stdout)
+		return nil
+	}
+	if args[0] == "all" {
+		writeUsage(os.Stdout)
+		for _, cmd := range commands {
+			if cmd.Name != "help" {
+				fmt.Println()
+				cmd.flagSet().Usage()
+			}
+		}
+		return nil
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return usageErrorf("unknown command %q", args[0])
+	}
+	if cmd.Name == "help" {
+		writeUsage(os.Stdout)
+		return nil
+	}
+	cmd.flagSet().Usage()
 	return nil
 }
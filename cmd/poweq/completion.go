@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/plot"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Shells of the completion command
+const (
+	SHELL_BASH = "bash"
+	SHELL_ZSH  = "zsh"
+	SHELL_FISH = "fish"
+)
+
+var shells = []string{SHELL_BASH, SHELL_ZSH, SHELL_FISH}
+
+// Flags whose value is a path
+var fileFlags = []string{"in", "out", "config", "rejects", "report", "history", "cache-dir"}
+
+// completionFlag is a flag as offered by the completion scripts.
+type completionFlag struct {
+	Name, Usage string
+	Bool        bool     // Takes no value
+	Files       bool     // The value is a path
+	Values      []string // Possible values, if known
+}
+
+// completedCommand is a command with its flags and positional arguments.
+type completedCommand struct {
+	Name, Summary string
+	Flags         []completionFlag
+	Files         bool     // Positional arguments are paths
+	Args          []string // Possible positional arguments, if known
+}
+
+// flagValues lists the values of a flag of cmd, nil when any value goes.
+func flagValues(cmd, name string) []string {
+	switch name {
+	case "alg", "methods":
+		return solver.Methods
+	case "criterion":
+		return solver.Criteria
+	case "scale":
+		return plot.Scales
+	case "mode":
+		return []string{MODE_RANDOM, MODE_STRESS}
+	case "families":
+		return familyNames()
+	case "error-format":
+		return errorFormats
+	case "log-level":
+		return config.LogLevels
+	case "log-format":
+		return config.LogFormats
+	case "in-format":
+		return jobfile.InputFormats
+	case "out-format":
+		return jobfile.OutputFormats
+	case "format":
+		switch cmd {
+		case "solve", "scan":
+			return jobfile.OutputFormats
+		case "generate":
+			return jobfile.InputFormats
+		case "bench", "check", "diff":
+			return []string{FORMAT_TABLE, FORMAT_CSV}
+		case "explain", "config":
+			return []string{FORMAT_TABLE, FORMAT_JSON}
+		case "plot":
+			return []string{FORMAT_ASCII, FORMAT_SVG}
+		}
+	}
+	return nil
+}
+
+func completionFlags(cmd string, flagSet *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	flagSet.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			Name: f.Name, Usage: f.Usage,
+			Bool:   ok && boolFlag.IsBoolFlag(),
+			Files:  slices.Contains(fileFlags, f.Name),
+			Values: flagValues(cmd, f.Name),
+		})
+	})
+	return flags
+}
+
+// completionCommands describes every command from its own flag set, so
+// the scripts follow the flags as they change.
+func completionCommands() []completedCommand {
+	var cmds []completedCommand
+	for _, cmd := range commands {
+		c := completedCommand{Name: cmd.Name, Summary: cmd.Summary, Flags: completionFlags(cmd.Name, cmd.flagSet())}
+		switch cmd.Name {
+		case "diff":
+			c.Files = true
+		case "config":
+			c.Args = []string{"show"}
+		case "completion":
+			c.Args = shells
+		case "help":
+			for _, other := range commands {
+				c.Args = append(c.Args, other.Name)
+			}
+			c.Args = append(c.Args, "all")
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds
+}
+
+// writeCompletion writes the completion script of shell.
+func writeCompletion(w io.Writer, shell string) error {
+	global := completionFlags("", globalFlags)
+	cmds := completionCommands()
+	switch shell {
+	case SHELL_BASH:
+		return writeBashCompletion(w, global, cmds)
+	case SHELL_ZSH:
+		return writeZshCompletion(w, global, cmds)
+	case SHELL_FISH:
+		return writeFishCompletion(w, global, cmds)
+	default:
+		return usageErrorf("unknown shell %q, expected %s", shell, strings.Join(shells, ", "))
+	}
+}
+
+func flagNames(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "-" + f.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// bashFlagCases completes the value of the flag before the cursor.
+func bashFlagCases(w io.Writer, flags []completionFlag, indent string) {
+	var free []string
+	for _, f := range flags {
+		switch {
+		case f.Bool:
+		case f.Files:
+			fmt.Fprintf(w, "%s-%s) compopt -o filenames; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", indent, f.Name)
+		case len(f.Values) > 0:
+			fmt.Fprintf(w, "%s-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", indent, f.Name, strings.Join(f.Values, " "))
+		default:
+			free = append(free, "-"+f.Name)
+		}
+	}
+	if len(free) > 0 {
+		fmt.Fprintf(w, "%s%s) return ;;\n", indent, strings.Join(free, "|"))
+	}
+}
+
+func writeBashCompletion(w io.Writer, global []completionFlag, cmds []completedCommand) error {
+	var names, valued []string
+	for _, c := range cmds {
+		names = append(names, c.Name)
+	}
+	for _, f := range global {
+		if !f.Bool {
+			valued = append(valued, "-"+f.Name)
+		}
+	}
+
+	fmt.Fprintln(w, "# bash completion for poweq, generated by 'poweq completion bash'")
+	fmt.Fprintln(w, "# Load it with: source <(poweq completion bash)")
+	fmt.Fprintln(w, "_poweq() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="" i`)
+	fmt.Fprintln(w, "    for ((i = 1; i < COMP_CWORD; i++)); do")
+	fmt.Fprintln(w, `        case "${COMP_WORDS[i]}" in`)
+	fmt.Fprintf(w, "            %s) ((i++)) ;;\n", strings.Join(valued, "|"))
+	fmt.Fprintln(w, "            -*) ;;")
+	fmt.Fprintln(w, `            *) cmd="${COMP_WORDS[i]}"; break ;;`)
+	fmt.Fprintln(w, "        esac")
+	fmt.Fprintln(w, "    done")
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	fmt.Fprintln(w, `        "")`)
+	fmt.Fprintln(w, `            case "$prev" in`)
+	bashFlagCases(w, global, "                ")
+	fmt.Fprintln(w, "            esac")
+	fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(names, " ")+" "+flagNames(global))
+	for _, c := range cmds {
+		fmt.Fprintf(w, "        %s)\n", c.Name)
+		fmt.Fprintln(w, `            case "$prev" in`)
+		bashFlagCases(w, c.Flags, "                ")
+		fmt.Fprintln(w, "            esac")
+		words := flagNames(c.Flags)
+		if len(c.Args) > 0 {
+			words = strings.TrimSpace(strings.Join(c.Args, " ") + " " + words)
+		}
+		if c.Files {
+			fmt.Fprintln(w, `            if [[ "$cur" != -* ]]; then compopt -o filenames; COMPREPLY=($(compgen -f -- "$cur")); return; fi`)
+		}
+		fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", words)
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	_, err := fmt.Fprintln(w, "complete -F _poweq poweq")
+	return err
+}
+
+// zshQuote escapes text for a single quoted _arguments spec.
+func zshQuote(text string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+func zshFlagSpecs(flags []completionFlag) []string {
+	specs := make([]string, len(flags))
+	for i, f := range flags {
+		spec := "'-" + f.Name + "[" + zshQuote(f.Usage) + "]"
+		switch {
+		case f.Bool:
+		case f.Files:
+			spec += ":file:_files"
+		case len(f.Values) > 0:
+			spec += ":value:(" + strings.Join(f.Values, " ") + ")"
+		default:
+			spec += ":value: "
+		}
+		specs[i] = spec + "'"
+	}
+	return specs
+}
+
+func writeZshCompletion(w io.Writer, global []completionFlag, cmds []completedCommand) error {
+	fmt.Fprintln(w, "#compdef poweq")
+	fmt.Fprintln(w, "# zsh completion for poweq, generated by 'poweq completion zsh'")
+	fmt.Fprintln(w, "# Load it with: source <(poweq completion zsh), or save it as _poweq in $fpath")
+	fmt.Fprintln(w, "_poweq() {")
+	fmt.Fprintln(w, "    local -a commands")
+	fmt.Fprintln(w, "    local state line")
+	fmt.Fprintln(w, "    commands=(")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "        '%s:%s'\n", c.Name, zshQuote(c.Summary))
+	}
+	fmt.Fprintln(w, "    )")
+	fmt.Fprintln(w, "    _arguments -C \\")
+	for _, spec := range zshFlagSpecs(global) {
+		fmt.Fprintf(w, "        %s \\\n", spec)
+	}
+	fmt.Fprintln(w, "        '1:command:->command' \\")
+	fmt.Fprintln(w, "        '*::arg:->args'")
+	fmt.Fprintln(w, "    case $state in")
+	fmt.Fprintln(w, "    command) _describe 'command' commands ;;")
+	fmt.Fprintln(w, "    args)")
+	fmt.Fprintln(w, "        case $line[1] in")
+	for _, c := range cmds {
+		specs := zshFlagSpecs(c.Flags)
+		switch {
+		case c.Files:
+			specs = append(specs, "'*:file:_files'")
+		case len(c.Args) > 0:
+			specs = append(specs, "'1:argument:("+strings.Join(c.Args, " ")+")'")
+		}
+		fmt.Fprintf(w, "        %s)\n", c.Name)
+		if len(specs) == 0 {
+			fmt.Fprintln(w, "            ;;")
+			continue
+		}
+		fmt.Fprintf(w, "            _arguments \\\n                %s ;;\n", strings.Join(specs, " \\\n                "))
+	}
+	fmt.Fprintln(w, "        esac ;;")
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	_, err := fmt.Fprintln(w, `_poweq "$@"`)
+	return err
+}
+
+// fishQuote quotes text for fish.
+func fishQuote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(text) + "'"
+}
+
+func writeFishFlags(w io.Writer, condition string, flags []completionFlag) {
+	for _, f := range flags {
+		line := "complete -c poweq -n " + fishQuote(condition) + " -o " + f.Name
+		switch {
+		case f.Bool:
+		case f.Files:
+			line += " -r -F"
+		case len(f.Values) > 0:
+			line += " -x -a " + fishQuote(strings.Join(f.Values, " "))
+		default:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line+" -d "+fishQuote(f.Usage))
+	}
+}
+
+func writeFishCompletion(w io.Writer, global []completionFlag, cmds []completedCommand) error {
+	fmt.Fprintln(w, "# fish completion for poweq, generated by 'poweq completion fish'")
+	fmt.Fprintln(w, "# Load it with: poweq completion fish | source")
+	fmt.Fprintln(w, "complete -c poweq -f")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "complete -c poweq -n __fish_use_subcommand -a %s -d %s\n", c.Name, fishQuote(c.Summary))
+	}
+	writeFishFlags(w, "__fish_use_subcommand", global)
+	for _, c := range cmds {
+		condition := "__fish_seen_subcommand_from " + c.Name
+		writeFishFlags(w, condition, c.Flags)
+		if c.Files {
+			fmt.Fprintf(w, "complete -c poweq -n %s -F\n", fishQuote(condition))
+		}
+		if len(c.Args) > 0 {
+			fmt.Fprintf(w, "complete -c poweq -n %s -a %s\n", fishQuote(condition), fishQuote(strings.Join(c.Args, " ")))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+)
+
+// conf is the configuration loaded before dispatching a command. Its
+// values are the defaults of the flags.
+var conf *config.Loaded
+
+// configPath finds the -config flag among the arguments of a command, as
+// the file must be loaded before the flags it provides defaults for.
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// addConfigFlag declares -config on a command. It is read by configPath
+// before parsing, the flag only keeps the parser from rejecting it.
+func addConfigFlag(fs *flag.FlagSet) {
+	fs.String("config", "", "Config file (default $"+config.ENV_CONFIG+", else poweq.yaml, .yml, .toml or .json in the working directory)")
+}
+
+// solverDefaults are the job defaults of the configuration.
+func solverDefaults() jobfile.Defaults {
+	s := conf.Solver
+	return jobfile.Defaults{
+		Tol: s.Tolerance, MaxIter: s.MaxIter, Algorithm: s.Algorithm,
+		Precision: s.Precision, Criterion: s.Criterion, Timeout: s.Timeout,
+	}
+}
+
+// outputFallback is the output format used when neither the flags nor the
+// file extension give one.
+func outputFallback(fallback string) string {
+	if conf.Output.Format != "" {
+		return conf.Output.Format
+	}
+	return fallback
+}
+
+// configEntry is one setting as printed by config show.
+type configEntry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env"`
+	Doc    string `json:"doc"`
+}
+
+func configEntries(l *config.Loaded) []configEntry {
+	var entries []configEntry
+	for _, key := range config.Keys() {
+		entries = append(entries, configEntry{key, l.Value(key), l.Sources[key], config.EnvName(key), config.Doc(key)})
+	}
+	return entries
+}
+
+func writeConfigTable(w io.Writer, l *config.Loaded) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, e := range configEntries(l) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Key, e.Value, e.Source)
+	}
+	return tw.Flush()
+}
+
+func writeConfigJSON(w io.Writer, l *config.Loaded) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(configEntries(l))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+)
+
+// TestFlagLayering checks the last layer of the configuration: flags
+// override the file and the environment, which override the defaults.
+func TestFlagLayering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "poweq.yaml")
+	data := "scan:\n  workers: 3\n  checkpoint: 20\n  rejects: file.csv\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"POWEQ_SCAN_CHECKPOINT=40"}
+
+	type values struct {
+		workers, checkpoint int
+		rejects             string
+	}
+	tests := []struct {
+		name string
+		args []string
+		env  []string
+		want values
+	}{
+		{"defaults", nil, nil, values{config.DEFAULT_WORKERS, config.DEFAULT_CHECKPOINT, config.DEFAULT_REJECTS}},
+		{"file", []string{"-config", path}, nil, values{3, 20, "file.csv"}},
+		{"env over file", []string{"-config=" + path}, env, values{3, 40, "file.csv"}},
+		{"flags over env", []string{"-config", path, "-checkpoint", "80", "-workers=5"}, env, values{5, 80, "file.csv"}},
+		{"empty flag", []string{"-config", path, "-rejects", ""}, env, values{3, 40, ""}},
+	}
+	saved := conf
+	t.Cleanup(func() { conf = saved })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if conf, err = config.Load(configPath(tt.args), tt.env); err != nil {
+				t.Fatal(err)
+			}
+			var opts scanFlags
+			fs := newFlagSet("scan")
+			opts.declare(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			got := values{opts.workers, opts.checkpoint, opts.rejectsOut}
+			if got != tt.want {
+				t.Errorf("workers, checkpoint, rejects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
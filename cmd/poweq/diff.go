@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Kinds of differences between two results files
+const (
+	DIFF_CHANGED     = "changed"     // A root moved beyond the tolerance
+	DIFF_APPEARED    = "appeared"    // A root only in the new file
+	DIFF_DISAPPEARED = "disappeared" // A root only in the old file
+	DIFF_ERROR       = "error"       // The errors of a job without roots changed
+	DIFF_MISSING     = "missing"     // A job only in the old file
+	DIFF_ADDED       = "added"       // A job only in the new file
+)
+
+// ErrRegression is returned by diff when the new results regress.
+var ErrRegression = errors.New("results regressed")
+
+// jobOutcome gathers the results of one job: its roots, or the errors
+// that explain why there are none.
+type jobOutcome struct {
+	Roots  []float64
+	Errors []string
+}
+
+// status sums up the errors of a job, empty when it has roots.
+func (o jobOutcome) status() string {
+	if len(o.Roots) > 0 {
+		return ""
+	}
+	errs := slices.Clone(o.Errors)
+	slices.Sort(errs)
+	return strings.Join(slices.Compact(errs), "; ")
+}
+
+func (o *jobOutcome) add(x float64, errText string) {
+	if errText == "" || errText == "<nil>" {
+		o.Roots = append(o.Roots, x)
+	} else {
+		o.Errors = append(o.Errors, errText)
+	}
+}
+
+// outcomes maps job ids, as written, to their results. Ids keep the
+// order of the file.
+type outcomes struct {
+	ids  []string
+	byId map[string]*jobOutcome
+}
+
+func (oc *outcomes) get(id string) *jobOutcome {
+	if oc.byId == nil {
+		oc.byId = map[string]*jobOutcome{}
+	}
+	outcome := oc.byId[id]
+	if outcome == nil {
+		outcome = &jobOutcome{}
+		oc.byId[id] = outcome
+		oc.ids = append(oc.ids, id)
+	}
+	return outcome
+}
+
+// readOutcomes loads a results file written by solve or scan. Both files
+// of a diff are joined by Id, so they are held in memory.
+func readOutcomes(r io.Reader, format string, opts jobfile.Options) (*outcomes, error) {
+	switch format {
+	case FORMAT_JSON, FORMAT_NDJSON:
+		return readJSONOutcomes(r)
+	case FORMAT_ARROW, FORMAT_PARQUET:
+		return readColumnarOutcomes(r, format, opts)
+	default:
+		return readCSVOutcomes(r, opts)
+	}
+}
+
+// readColumnarOutcomes reads an Arrow or Parquet results file as a file
+// of jobs, the result columns coming as passthrough values.
+func readColumnarOutcomes(r io.Reader, format string, opts jobfile.Options) (*outcomes, error) {
+	reader, err := jobfile.NewReader(r, format, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range []string{jobfile.COL_X, jobfile.COL_ERROR} {
+		if !slices.ContainsFunc(reader.ExtraColumns(), func(name string) bool { return strings.EqualFold(name, column) }) {
+			return nil, fmt.Errorf("missing results column %q", column)
+		}
+	}
+
+	oc := &outcomes{}
+	for rec := range reader.Records() {
+		xText, _ := rec.ExtraValue(jobfile.COL_X)
+		errText, _ := rec.ExtraValue(jobfile.COL_ERROR)
+		x, err := strconv.ParseFloat(xText, 64)
+		if err != nil && errText == "" {
+			return nil, fmt.Errorf("row %d: column %q: invalid number %q", rec.Line, jobfile.COL_X, xText)
+		}
+		oc.get(rec.RawId).add(x, errText)
+	}
+	return oc, reader.Err()
+}
+
+func readCSVOutcomes(r io.Reader, opts jobfile.Options) (*outcomes, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("results file is empty, expected a header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range []string{jobfile.COL_ID, jobfile.COL_X, jobfile.COL_ERROR} {
+		if _, ok := index[strings.ToLower(column)]; !ok {
+			return nil, fmt.Errorf("missing results column %q", column)
+		}
+	}
+	field := func(record []string, column string) string {
+		if i := index[strings.ToLower(column)]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	oc := &outcomes{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return oc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		x, err := strconv.ParseFloat(field(record, jobfile.COL_X), 64)
+		errText := field(record, jobfile.COL_ERROR)
+		if err != nil && (errText == "" || errText == "<nil>") {
+			return nil, fmt.Errorf("line %d: column %q: invalid number %q", line, jobfile.COL_X, field(record, jobfile.COL_X))
+		}
+		oc.get(field(record, jobfile.COL_ID)).add(x, errText)
+	}
+}
+
+func readJSONOutcomes(r io.Reader) (*outcomes, error) {
+	oc := &outcomes{}
+	add := func(data []byte) error {
+		var resp solver.SolveResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		outcome := oc.get(strconv.Itoa(resp.Id))
+		if resp.Error != "" {
+			outcome.Errors = append(outcome.Errors, resp.Error)
+		}
+		for _, solution := range resp.Solutions {
+			outcome.add(solution.X, solution.Error)
+		}
+		return nil
+	}
+
+	// A JSON array or one object per line, told apart by the first byte
+	buffered := bufio.NewReader(r)
+	first, err := peekNonSpace(buffered)
+	if err == io.EOF {
+		return oc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(buffered)
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("result %d: %w", len(oc.ids)+1, err)
+		}
+		if err := add(raw); err != nil {
+			return nil, fmt.Errorf("result %d: %w", len(oc.ids)+1, err)
+		}
+	}
+	return oc, nil
+}
+
+// peekNonSpace returns the first byte after white space, leaving it unread.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// difference is one change between two results files.
+type difference struct {
+	Id       string
+	Kind     string
+	Old, New string
+}
+
+// diffConfig holds the settings of a diff, as parsed from the flags.
+type diffConfig struct {
+	RelTol float64 // Relative distance under which a root is unchanged
+	Strict bool    // Count appeared roots and added jobs as regressions
+}
+
+// isRegression tells the differences that fail a diff.
+func (cfg diffConfig) isRegression(d difference) bool {
+	switch d.Kind {
+	case DIFF_APPEARED, DIFF_ADDED:
+		return cfg.Strict
+	case DIFF_ERROR:
+		// An error going away is an improvement, unless strict
+		return d.New != "" || cfg.Strict
+	default:
+		return true
+	}
+}
+
+func (cfg diffConfig) same(a, b float64) bool {
+	return math.Abs(a-b) <= cfg.RelTol*math.Max(1, math.Abs(a))
+}
+
+// diffRoots compares the roots of one job. With as many roots on both
+// sides they are paired in order; otherwise roots are matched to the
+// closest one within the tolerance and the others appeared or vanished.
+func (cfg diffConfig) diffRoots(id string, old, new []float64) []difference {
+	old, new = slices.Sorted(slices.Values(old)), slices.Sorted(slices.Values(new))
+	format := func(x float64) string { return solver.FormatFloat(x, solver.DIGITS_EXACT) }
+	var diffs []difference
+
+	if len(old) == len(new) {
+		for i := range old {
+			if !cfg.same(old[i], new[i]) {
+				diffs = append(diffs, difference{id, DIFF_CHANGED, format(old[i]), format(new[i])})
+			}
+		}
+		return diffs
+	}
+
+	matched := make([]bool, len(new))
+	for _, x := range old {
+		best := -1
+		for j, y := range new {
+			if !matched[j] && cfg.same(x, y) && (best < 0 || math.Abs(x-y) < math.Abs(x-new[best])) {
+				best = j
+			}
+		}
+		if best < 0 {
+			diffs = append(diffs, difference{id, DIFF_DISAPPEARED, format(x), ""})
+		} else {
+			matched[best] = true
+		}
+	}
+	for j, y := range new {
+		if !matched[j] {
+			diffs = append(diffs, difference{id, DIFF_APPEARED, "", format(y)})
+		}
+	}
+	return diffs
+}
+
+// diffOutcomes joins both files by Id, in the order of the old file then
+// the jobs added by the new one.
+func (cfg diffConfig) diffOutcomes(old, new *outcomes) []difference {
+	var diffs []difference
+	for _, id := range old.ids {
+		before := old.byId[id]
+		after, ok := new.byId[id]
+		if !ok {
+			diffs = append(diffs, difference{id, DIFF_MISSING, describe(before), ""})
+			continue
+		}
+		diffs = append(diffs, cfg.diffRoots(id, before.Roots, after.Roots)...)
+		if before.status() != after.status() {
+			diffs = append(diffs, difference{id, DIFF_ERROR, before.status(), after.status()})
+		}
+	}
+	for _, id := range new.ids {
+		if _, ok := old.byId[id]; !ok {
+			diffs = append(diffs, difference{id, DIFF_ADDED, "", describe(new.byId[id])})
+		}
+	}
+	return diffs
+}
+
+// describe sums up the results of a job in one cell.
+func describe(o *jobOutcome) string {
+	if len(o.Roots) == 0 {
+		return o.status()
+	}
+	return formatRoots(o.Roots)
+}
+
+func writeDiffTable(w io.Writer, diffs []difference, cfg diffConfig) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCHANGE\tOLD\tNEW\tREGRESSION")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", d.Id, d.Kind, d.Old, d.New, cfg.isRegression(d))
+	}
+	return tw.Flush()
+}
+
+func writeDiffCSV(w io.Writer, diffs []difference, cfg diffConfig) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Id", "Change", "Old", "New", "Regression"})
+	for _, d := range diffs {
+		writer.Write([]string{d.Id, d.Kind, d.Old, d.New, strconv.FormatBool(cfg.isRegression(d))})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// summarize counts the differences by kind, for the logs.
+func summarize(diffs []difference) map[string]int {
+	counts := map[string]int{}
+	for _, d := range diffs {
+		counts[d.Kind]++
+	}
+	return counts
+}
+
+func formatCounts(counts map[string]int) string {
+	var parts []string
+	for _, kind := range slices.Sorted(maps.Keys(counts)) {
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, counts[kind]))
+	}
+	return strings.Join(parts, " ")
+}
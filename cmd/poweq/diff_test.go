@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+)
+
+func TestDiffExitCodes(t *testing.T) {
+	quiet(t)
+	const header = "Id,N,M,K,A,B,X,Steps,Method,Error\n"
+	const base = header +
+		"1,2,2,1,0,10,2,5,newton,<nil>\n" +
+		"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+		"2,2,2,100,0,10,-1,0,,no solutions exist for the given parameters\n"
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		args []string
+		want int
+	}{
+		{"identical", base, base, nil, EXIT_OK},
+		{"within tolerance", base, header +
+			"1,2,2,1,0,10,2.0000000001,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+			"2,2,2,100,0,10,-1,0,,no solutions exist for the given parameters\n", nil, EXIT_OK},
+		{"root changed", base, header +
+			"1,2,2,1,0,10,2.1,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+			"2,2,2,100,0,10,-1,0,,no solutions exist for the given parameters\n", nil, EXIT_FAILURE},
+		{"loose tolerance", base, header +
+			"1,2,2,1,0,10,2.1,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+			"2,2,2,100,0,10,-1,0,,no solutions exist for the given parameters\n", []string{"-rel-tol", "0.1"}, EXIT_OK},
+		{"root disappeared", base, header +
+			"1,2,2,1,0,10,2,5,newton,<nil>\n" +
+			"2,2,2,100,0,10,-1,0,,no solutions exist for the given parameters\n", nil, EXIT_FAILURE},
+		{"job missing", base, header +
+			"1,2,2,1,0,10,2,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n", nil, EXIT_FAILURE},
+		{"job added", base, base + "3,2,2,1,0,10,2,5,newton,<nil>\n", nil, EXIT_OK},
+		{"job added, strict", base, base + "3,2,2,1,0,10,2,5,newton,<nil>\n", []string{"-strict"}, EXIT_FAILURE},
+		{"error fixed", base, header +
+			"1,2,2,1,0,10,2,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+			"2,2,2,100,0,10,3,7,newton,<nil>\n", nil, EXIT_OK},
+		{"error fixed, strict", base, header +
+			"1,2,2,1,0,10,2,5,newton,<nil>\n" +
+			"1,2,2,1,0,10,4,6,newton,<nil>\n" +
+			"2,2,2,100,0,10,3,7,newton,<nil>\n", []string{"-strict"}, EXIT_FAILURE},
+		{"invalid results", base, "Id,N\n1,2\n", nil, EXIT_INVALID},
+		{"missing file", base, "", []string{"-in-format", "csv"}, EXIT_IO},
+		{"bad flag", base, base, []string{"-format", "xml"}, EXIT_USAGE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldPath, newPath := filepath.Join(dir, "old.csv"), filepath.Join(dir, "new.csv")
+			if err := os.WriteFile(oldPath, []byte(tt.old), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.new != "" {
+				if err := os.WriteFile(newPath, []byte(tt.new), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			args := append([]string{"-out", filepath.Join(dir, "diff.txt")}, tt.args...)
+			err := diffCommand(append(args, oldPath, newPath))
+			if code, _ := exitCode(err); code != tt.want {
+				t.Errorf("exit code = %d (%v), want %d", code, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffFormats diffs results written in every format against CSV ones.
+func TestDiffFormats(t *testing.T) {
+	quiet(t)
+	defaultConfig(t)
+	dir := t.TempDir()
+	jobs, fewer := filepath.Join(dir, "jobs.csv"), filepath.Join(dir, "fewer.csv")
+	writeTestJobs(t, jobs, 30, 100)
+	writeTestJobs(t, fewer, 20, 100)
+	scan := func(in, name string) string {
+		out := filepath.Join(dir, name)
+		if _, err := scanCommand([]string{"-in", in, "-out", out, "-rejects", ""}); err != nil {
+			t.Fatalf("scan %s: %v", name, err)
+		}
+		return out
+	}
+	csvResults := scan(jobs, "results.csv")
+	fewerResults := scan(fewer, "fewer.csv")
+	for _, format := range jobfile.InputFormats {
+		results := scan(jobs, "results."+format)
+		for _, tt := range []struct {
+			old, new string
+			want     int
+		}{
+			{results, csvResults, EXIT_OK},
+			{csvResults, results, EXIT_OK},
+			{results, fewerResults, EXIT_FAILURE},
+		} {
+			err := diffCommand([]string{"-out", filepath.Join(dir, "diff.txt"), tt.old, tt.new})
+			if code, _ := exitCode(err); code != tt.want {
+				t.Errorf("diff %s %s: exit code = %d (%v), want %d", filepath.Base(tt.old), filepath.Base(tt.new), code, err, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// writeExplanation prints an explanation as labelled lines, numbers with
+// digits significant digits.
+func writeExplanation(w io.Writer, job solver.Job, e solver.Explanation, digits int) error {
+	num := func(x float64) string { return solver.FormatFloat(x, digits) }
+	nums := func(xs []float64) string {
+		cells := make([]string, len(xs))
+		for i, x := range xs {
+			cells[i] = num(x)
+		}
+		return strings.Join(cells, ", ")
+	}
+	inRange := func(x float64) string {
+		if x < job.A || x > job.B {
+			return " (outside [a, b])"
+		}
+		return ""
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Equation\t%s\n", e.Equation)
+	fmt.Fprintf(tw, "Log form\t%s\n", e.LogForm)
+	fmt.Fprintf(tw, "Function\t%s\n", e.Function)
+	fmt.Fprintf(tw, "Derivative\t%s\n", e.Derivative)
+	if e.EdgeCase != "" {
+		fmt.Fprintf(tw, "Edge case\t%s, solved in closed form\n", e.EdgeCase)
+	}
+	if e.XLimit != nil {
+		fmt.Fprintf(tw, "x_limit\t%s\n", num(*e.XLimit))
+		fmt.Fprintf(tw, "f(x_limit)\t%s\n", num(*e.FXLimit))
+	}
+	fmt.Fprintf(tw, "Solutions exist\t%t\n", e.SolutionsExist)
+	fmt.Fprintf(tw, "Expected roots\t%d in [%s, %s]\n", e.ExpectedRoots, num(job.A), num(job.B))
+	for _, reason := range e.Reasoning {
+		fmt.Fprintf(tw, "\t- %s\n", reason)
+	}
+	if e.Analytic != nil {
+		fmt.Fprintf(tw, "Analytic root\t%s, the methods are skipped\n", num(*e.Analytic))
+	} else {
+		fmt.Fprintf(tw, "Initial guesses\t%s (newton)\n", nums(e.InitialGuesses))
+		intervals := make([]string, len(e.Intervals))
+		for i, interval := range e.Intervals {
+			intervals[i] = "[" + nums(interval[:]) + "]"
+		}
+		fmt.Fprintf(tw, "Intervals\t%s (bisection)\n", strings.Join(intervals, " "))
+	}
+	if lw := e.LambertW; lw != nil {
+		fmt.Fprintf(tw, "Lambert W\tx = -W(z)/c with c = ln(m)/n = %s, z = -c K^(1/n) = %s\n", num(lw.C), num(lw.Z))
+		if lw.W0 == nil && lw.Wm1 == nil {
+			fmt.Fprintf(tw, "\tz < -1/e: no real branch, no root\n")
+		}
+		if lw.W0 != nil {
+			fmt.Fprintf(tw, "\tW0(z) = %s, x = %s%s\n", num(*lw.W0), num(*lw.Root0), inRange(*lw.Root0))
+		}
+		if lw.Wm1 != nil {
+			fmt.Fprintf(tw, "\tW-1(z) = %s, x = %s%s\n", num(*lw.Wm1), num(*lw.RootM1), inRange(*lw.RootM1))
+		}
+	}
+	if len(e.ExactRoots) > 0 {
+		fmt.Fprintf(tw, "Exact roots\t%s\n", nums(e.ExactRoots))
+	} else {
+		fmt.Fprintf(tw, "Exact roots\tnone in [a, b]\n")
+	}
+	return tw.Flush()
+}
+
+func writeExplanationJSON(w io.Writer, e solver.Explanation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // The reasoning compares with < and >=
+	return encoder.Encode(e)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+)
+
+// File formats for jobs and results, read and written by jobfile
+const (
+	FORMAT_CSV     = jobfile.FORMAT_CSV
+	FORMAT_JSON    = jobfile.FORMAT_JSON
+	FORMAT_NDJSON  = jobfile.FORMAT_NDJSON
+	FORMAT_TABLE   = jobfile.FORMAT_TABLE
+	FORMAT_ARROW   = jobfile.FORMAT_ARROW
+	FORMAT_PARQUET = jobfile.FORMAT_PARQUET
+
+	// Formats of plot
+	FORMAT_ASCII = "ascii"
+	FORMAT_SVG   = "svg"
+)
+
+// STDIO is the file name standing for stdin or stdout
+const STDIO = "-"
+
+// resolveFormat picks the format of path: the explicit one if given,
+// else from the file extension, else fallback.
+func resolveFormat(format, path, fallback string, allowed []string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			format = FORMAT_JSON
+		case ".ndjson", ".jsonl":
+			format = FORMAT_NDJSON
+		case ".csv", ".tsv":
+			format = FORMAT_CSV
+		case ".arrow", ".feather", ".ipc":
+			format = FORMAT_ARROW
+		case ".parquet", ".pq":
+			format = FORMAT_PARQUET
+		case ".svg":
+			format = FORMAT_SVG
+		default:
+			format = fallback
+		}
+	}
+	format = strings.ToLower(format)
+	if !slices.Contains(allowed, format) {
+		return "", fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(allowed, ", "))
+	}
+	return format, nil
+}
+
+// pickFormat gives precedence to the format flag specific to one side.
+func pickFormat(specific, common string) string {
+	if specific != "" {
+		return specific
+	}
+	return common
+}
+
+// openInput opens path for reading, STDIO standing for stdin.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == STDIO {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
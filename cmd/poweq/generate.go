@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Distributions of the generated parameters
+const (
+	DIST_UNIFORM    = "uniform"
+	DIST_LOGUNIFORM = "loguniform" // Uniform exponent, for parameters spanning decades
+	DIST_FIXED      = "fixed"
+)
+
+// Root count classes a generated job can be asked to fall in. Roots are
+// counted in [A, B].
+const (
+	CLASS_ANY      = "any"
+	CLASS_SOLVABLE = "solvable" // At least one root
+	CLASS_NONE     = "none"     // No root, for negative testing
+	CLASS_ONE      = "one"
+	CLASS_TWO      = "two"     // Two well separated roots
+	CLASS_TANGENT  = "tangent" // Two roots merging into a double root at the top of f
+)
+
+var rootClasses = []string{CLASS_ANY, CLASS_SOLVABLE, CLASS_NONE, CLASS_ONE, CLASS_TWO, CLASS_TANGENT}
+
+const (
+	// Largest maximum of f for a job to count as tangent. Below it the two
+	// roots are so close that the derivative nearly vanishes at both.
+	TANGENT_GAP = 1e-4
+	// Draws allowed per job before giving up on its class
+	MAX_DRAWS = 100000
+)
+
+// paramDist is the distribution of one parameter.
+type paramDist struct {
+	Kind     string
+	Min, Max float64
+}
+
+// parseDist reads "uniform:min:max", "loguniform:min:max", "fixed:value"
+// or a bare number, which is fixed.
+func parseDist(name, spec string) (paramDist, error) {
+	if value, err := strconv.ParseFloat(spec, 64); err == nil {
+		return paramDist{Kind: DIST_FIXED, Min: value, Max: value}, nil
+	}
+	parts := strings.Split(spec, ":")
+	dist := paramDist{Kind: strings.ToLower(parts[0])}
+	var err error
+	switch {
+	case dist.Kind == DIST_FIXED && len(parts) == 2:
+		dist.Min, err = strconv.ParseFloat(parts[1], 64)
+		dist.Max = dist.Min
+	case (dist.Kind == DIST_UNIFORM || dist.Kind == DIST_LOGUNIFORM) && len(parts) == 3:
+		if dist.Min, err = strconv.ParseFloat(parts[1], 64); err == nil {
+			dist.Max, err = strconv.ParseFloat(parts[2], 64)
+		}
+	default:
+		return dist, fmt.Errorf("-%s: expected uniform:min:max, loguniform:min:max or fixed:value, got %q", name, spec)
+	}
+	if err != nil {
+		return dist, fmt.Errorf("-%s: %w", name, err)
+	}
+	if dist.Min > dist.Max {
+		return dist, fmt.Errorf("-%s: min %g is above max %g", name, dist.Min, dist.Max)
+	}
+	if dist.Kind == DIST_LOGUNIFORM && dist.Min <= 0 {
+		return dist, fmt.Errorf("-%s: loguniform needs a positive range", name)
+	}
+	return dist, nil
+}
+
+func (d paramDist) draw(rng *rand.Rand) float64 {
+	switch d.Kind {
+	case DIST_UNIFORM:
+		return d.Min + rng.Float64()*(d.Max-d.Min)
+	case DIST_LOGUNIFORM:
+		return math.Exp(math.Log(d.Min) + rng.Float64()*(math.Log(d.Max)-math.Log(d.Min)))
+	default:
+		return d.Min
+	}
+}
+
+func (d paramDist) String() string {
+	if d.Kind == DIST_FIXED {
+		return fmt.Sprintf("%s:%g", d.Kind, d.Min)
+	}
+	return fmt.Sprintf("%s:%g:%g", d.Kind, d.Min, d.Max)
+}
+
+// genConfig describes the jobs to generate.
+type genConfig struct {
+	N, M, K, A, B, Tol, MaxIter paramDist
+	Mix                         map[string]float64 // Share of jobs by root class, the rest is CLASS_SOLVABLE
+}
+
+// defaultGenConfig uses the ranges generate has always drawn from.
+func defaultGenConfig() genConfig {
+	return genConfig{
+		N:       paramDist{DIST_UNIFORM, 0.1, 10},
+		M:       paramDist{DIST_UNIFORM, 1.1, 6},
+		K:       paramDist{DIST_UNIFORM, 0.01, 10000},
+		A:       paramDist{DIST_FIXED, 1e-6, 1e-6},
+		B:       paramDist{DIST_UNIFORM, 10, 1e6},
+		Tol:     paramDist{DIST_FIXED, 1e-6, 1e-6},
+		MaxIter: paramDist{DIST_UNIFORM, 10, 100},
+		Mix:     map[string]float64{},
+	}
+}
+
+// paramFlag is a parameter of genConfig with the flag setting its
+// distribution.
+type paramFlag struct {
+	name string
+	dst  *paramDist
+}
+
+// paramFlags lists the parameters of cfg in the order of their flags.
+func (cfg *genConfig) paramFlags() []paramFlag {
+	return []paramFlag{
+		{"n", &cfg.N}, {"m", &cfg.M}, {"K", &cfg.K},
+		{"a", &cfg.A}, {"b", &cfg.B},
+		{"tol", &cfg.Tol}, {"maxIter", &cfg.MaxIter},
+	}
+}
+
+// parseMix reads "two=0.3,tangent=0.2,none=0.1". Shares are fractions of
+// the jobs and must not add up to more than 1.
+func parseMix(spec string) (map[string]float64, error) {
+	mix := map[string]float64{}
+	total := 0.0
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(part, "=")
+		class = strings.ToLower(strings.TrimSpace(class))
+		if !ok || !slices.Contains(rootClasses, class) {
+			return nil, fmt.Errorf("-mix: expected class=share with class one of %s, got %q", strings.Join(rootClasses, ", "), part)
+		}
+		share, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || share < 0 {
+			return nil, fmt.Errorf("-mix: invalid share %q for %s", value, class)
+		}
+		mix[class] += share
+		total += share
+	}
+	if total > 1+1e-9 {
+		return nil, fmt.Errorf("-mix: shares add up to %g, more than 1", total)
+	}
+	return mix, nil
+}
+
+// classes assigns a root class to each of count jobs. The numbers of
+// each class are as close to the mix as whole jobs allow, their order
+// is shuffled.
+func (cfg genConfig) classes(rng *rand.Rand, count int) []string {
+	classes := make([]string, 0, count)
+	for _, class := range rootClasses {
+		if class == CLASS_SOLVABLE {
+			continue
+		}
+		n := int(math.Round(cfg.Mix[class] * float64(count)))
+		for i := 0; i < n && len(classes) < count; i++ {
+			classes = append(classes, class)
+		}
+	}
+	for len(classes) < count {
+		classes = append(classes, CLASS_SOLVABLE)
+	}
+	rng.Shuffle(len(classes), func(i, j int) { classes[i], classes[j] = classes[j], classes[i] })
+	return classes
+}
+
+// topGap is f at its top n/ln(m), the gap setTop sets.
+func topGap(job solver.Job) float64 {
+	top := job.N / math.Log(job.M)
+	return job.N*math.Log(top) - job.N - math.Log(job.K)
+}
+
+func inClass(job solver.Job, class string) bool {
+	count := job.CountRoots()
+	// Two roots need the top of f inside [A, B]
+	tangent := count == 2 && topGap(job) < TANGENT_GAP
+	switch class {
+	case CLASS_SOLVABLE:
+		return count > 0
+	case CLASS_NONE:
+		return count == 0
+	case CLASS_ONE:
+		return count == 1
+	case CLASS_TWO:
+		return count == 2 && !tangent
+	case CLASS_TANGENT:
+		return tangent
+	default:
+		return true
+	}
+}
+
+// drawJob draws the parameters of a job, whatever its class.
+func (cfg genConfig) drawJob(rng *rand.Rand, id int) solver.Job {
+	return solver.Job{
+		Id:      id,
+		N:       cfg.N.draw(rng),
+		M:       cfg.M.draw(rng),
+		K:       cfg.K.draw(rng),
+		A:       cfg.A.draw(rng),
+		B:       cfg.B.draw(rng),
+		Tol:     cfg.Tol.draw(rng),
+		MaxIter: int(math.Round(cfg.MaxIter.draw(rng))),
+	}
+}
+
+// makeTangent sets K so that the top of f lies TANGENT_GAP*u above
+// zero, u uniform in [0, 1). It fails when the top is outside [A, B].
+func makeTangent(rng *rand.Rand, job *solver.Job) bool {
+	return setTop(job, TANGENT_GAP*rng.Float64())
+}
+
+// setTop sets K so that the top of f, at n/ln(m), is gap above zero:
+// the two roots then lie on both sides of the top, closer as gap is
+// smaller. It fails when the top is outside [A, B].
+func setTop(job *solver.Job, gap float64) bool {
+	if job.N == 0 || job.M == 1 {
+		return false
+	}
+	top := job.N / math.Log(job.M)
+	if top <= job.A || top >= job.B {
+		return false
+	}
+	job.K = math.Exp(job.N*math.Log(top) - job.N - gap)
+	return true
+}
+
+// generateJobs yields count valid jobs numbered from 1, each in the root
+// class the mix assigns it. The same rng state always yields the same
+// jobs. Jobs out of their class are drawn again, up to MAX_DRAWS times;
+// the number of extra draws is reported through redraws.
+func generateJobs(rng *rand.Rand, cfg genConfig, count int, redraws *int) iter.Seq2[solver.Job, error] {
+	return func(yield func(solver.Job, error) bool) {
+		for i, class := range cfg.classes(rng, count) {
+			job, err := cfg.drawInClass(rng, i+1, class, redraws)
+			if !yield(job, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (cfg genConfig) drawInClass(rng *rand.Rand, id int, class string, redraws *int) (solver.Job, error) {
+	for draw := 0; draw < MAX_DRAWS; draw++ {
+		if draw > 0 && redraws != nil {
+			*redraws++
+		}
+		job := cfg.drawJob(rng, id)
+		// Tangent jobs are built rather than found, K is set to match
+		if class == CLASS_TANGENT && !makeTangent(rng, &job) {
+			continue
+		}
+		if job.Validate() == nil && inClass(job, class) {
+			return job, nil
+		}
+	}
+	return solver.Job{}, fmt.Errorf("job %d: no %q job found in %d draws, widen the parameter ranges", id, class, MAX_DRAWS)
+}
+
+// genJob is a generated job. Stress jobs also carry their family and
+// their reference roots, which are written as passthrough columns.
+type genJob struct {
+	solver.Job
+	Family string
+	Roots  []float64
+}
+
+// Passthrough columns of stress jobs, read back by check
+const (
+	COL_FAMILY = "Family"
+	COL_ROOTS  = "Roots" // Space separated, empty when there is none
+)
+
+// plainJobs wraps jobs without family nor reference roots.
+func plainJobs(jobs iter.Seq2[solver.Job, error]) iter.Seq2[genJob, error] {
+	return func(yield func(genJob, error) bool) {
+		for job, err := range jobs {
+			if !yield(genJob{Job: job}, err) {
+				return
+			}
+		}
+	}
+}
+
+func formatRoots(roots []float64) string {
+	fields := make([]string, len(roots))
+	for i, x := range roots {
+		fields[i] = solver.FormatFloat(x, solver.DIGITS_EXACT)
+	}
+	return strings.Join(fields, " ")
+}
+
+// writeJobs writes jobs in one of the input formats, so that the file
+// can be fed to solve or scan as is. Numbers are written exactly.
+// With labeled, the family and the roots of each job are added.
+func writeJobs(w io.Writer, format string, jobs iter.Seq2[genJob, error], labeled bool) (int, error) {
+	written := 0
+	switch format {
+	case FORMAT_JSON, FORMAT_NDJSON:
+		writer := bufio.NewWriter(w)
+		array := format == FORMAT_JSON
+		if array {
+			writer.WriteString("[\n")
+		}
+		for job, err := range jobs {
+			if err != nil {
+				writer.Flush()
+				return written, err
+			}
+			if array && written > 0 {
+				writer.WriteString(",\n")
+			}
+			req := solver.NewSolveRequest(job.Job, "")
+			if labeled {
+				req.Extra = map[string]string{
+					strings.ToLower(COL_FAMILY): job.Family,
+					strings.ToLower(COL_ROOTS):  formatRoots(job.Roots),
+				}
+			}
+			data, err := json.Marshal(req)
+			if err != nil {
+				return written, err
+			}
+			writer.Write(data)
+			if !array {
+				writer.WriteByte('\n')
+			}
+			written++
+		}
+		if array {
+			writer.WriteString("\n]\n")
+		}
+		return written, writer.Flush()
+
+	case FORMAT_CSV:
+		writer := csv.NewWriter(w)
+		header := []string{jobfile.COL_ID, jobfile.COL_N, jobfile.COL_M, jobfile.COL_K, jobfile.COL_A, jobfile.COL_B, jobfile.COL_TOL, jobfile.COL_MAX_ITER}
+		if labeled {
+			header = append(header, COL_FAMILY, COL_ROOTS)
+		}
+		writer.Write(header)
+		for job, err := range jobs {
+			if err != nil {
+				writer.Flush()
+				return written, err
+			}
+			record := []string{
+				strconv.Itoa(job.Id),
+				solver.FormatFloat(job.N, solver.DIGITS_EXACT),
+				solver.FormatFloat(job.M, solver.DIGITS_EXACT),
+				solver.FormatFloat(job.K, solver.DIGITS_EXACT),
+				solver.FormatFloat(job.A, solver.DIGITS_EXACT),
+				solver.FormatFloat(job.B, solver.DIGITS_EXACT),
+				solver.FormatFloat(job.Tol, solver.DIGITS_EXACT),
+				strconv.Itoa(job.MaxIter),
+			}
+			if labeled {
+				record = append(record, job.Family, formatRoots(job.Roots))
+			}
+			writer.Write(record)
+			written++
+		}
+		writer.Flush()
+		return written, writer.Error()
+
+	case FORMAT_ARROW, FORMAT_PARQUET:
+		var extraNames []string
+		if labeled {
+			extraNames = []string{COL_FAMILY, COL_ROOTS}
+		}
+		writer, err := jobfile.NewJobWriter(w, format, extraNames)
+		if err != nil {
+			return written, err
+		}
+		for job, err := range jobs {
+			if err != nil {
+				writer.Close()
+				return written, err
+			}
+			rec := jobfile.Record{Job: job.Job, ExtraNames: extraNames}
+			if labeled {
+				rec.Extra = []string{job.Family, formatRoots(job.Roots)}
+			}
+			if err := writer.WriteJob(rec); err != nil {
+				return written, err
+			}
+			written++
+		}
+		return written, writer.Close()
+	}
+	return written, errors.New("unsupported jobs format " + format)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+)
+
+// readJobs reads back every record of a jobs file.
+func readJobs(t *testing.T, path, format string) []jobfile.Record {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := jobfile.NewReader(file, format, jobfile.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []jobfile.Record
+	for rec := range reader.Records() {
+		if rec.Err != nil {
+			t.Fatalf("%s: line %d: %v", path, rec.Line, rec.Err)
+		}
+		records = append(records, rec)
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// TestGenerateFormats checks that every input format gets the same
+// stress jobs, with their family and roots.
+func TestGenerateFormats(t *testing.T) {
+	quiet(t)
+	dir := t.TempDir()
+	var want []jobfile.Record
+	for _, format := range jobfile.InputFormats {
+		path := filepath.Join(dir, "jobs."+format)
+		if err := generateCommand([]string{"-mode", MODE_STRESS, "-N", "12", "-seed", "7", "-out", path}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		records := readJobs(t, path, format)
+		if want == nil {
+			want = records
+			if len(want) != 12 {
+				t.Fatalf("%s: %d jobs, want 12", format, len(want))
+			}
+			continue
+		}
+		if len(records) != len(want) {
+			t.Fatalf("%s: %d jobs, want %d", format, len(records), len(want))
+		}
+		for i, rec := range records {
+			family, _ := rec.ExtraValue(COL_FAMILY)
+			roots, _ := rec.ExtraValue(COL_ROOTS)
+			wantFamily, _ := want[i].ExtraValue(COL_FAMILY)
+			wantRoots, _ := want[i].ExtraValue(COL_ROOTS)
+			if rec.Job != want[i].Job || family != wantFamily || roots != wantRoots {
+				t.Errorf("%s: job %d = %+v %q %q, want %+v %q %q", format, i+1, rec.Job, family, roots, want[i].Job, wantFamily, wantRoots)
+			}
+		}
+	}
+}
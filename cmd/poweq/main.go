@@ -1,68 +1,64 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"log/slog"
 	"os"
 	"runtime"
 	"time"
-	"log"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
 )
 
-const(
+const (
 	KILO = 1024
 )
 
-var logger *log.Logger
-
-func init() {
-	logger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-}
+// logger is replaced once the configuration is loaded
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 
 func main() {
 	var mStart, mEnd runtime.MemStats
-	// logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	runtime.ReadMemStats(&mStart)
 
 	start := time.Now()
-	// CRASH if no arguments!
-	if len(os.Args) < 2 {
-		logger.Println("no command provided", "usage", "Available commands: solve, scan")
-		return
+	args, err := parseGlobalFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(EXIT_OK)
+	}
+	if err != nil {
+		os.Exit(reportError("", err, *errorFormat))
+	}
+	if len(args) == 0 {
+		writeUsage(os.Stderr)
+		os.Exit(reportError("", usageErrorf("no command provided"), *errorFormat))
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		os.Exit(reportError(args[0], usageErrorf("unknown command %q, run 'poweq help' for the list", args[0]), *errorFormat))
 	}
-	// Before this step, n, m and K are default values
-	switch os.Args[1] {
-	case "scan":
-		_, err := scanCommand(os.Args[2:])
-		if err != nil {
-			logger.Println("scan failed", "error", err)
-			return
-		}
-
-	case "solve":
-		solutions, err := solveCommand(os.Args[2:])
-		if err != nil {
-			logger.Println("solve failed", "error", err)
-			return
-		}
-		displaySolutions(solutions)
 
-	case "generate":
-		err := generateCommand(os.Args[2:])
-		if err != nil {
-			logger.Println("generate failed", "error", err)
-			return
-		}
+	// Defaults of the flags: config file, then environment
+	if conf, err = config.Load(configPath(args[1:]), os.Environ()); err != nil {
+		os.Exit(reportError(cmd.Name, tagged(ErrInvalidInput, err), *errorFormat))
+	}
+	if err := setupLogging(); err != nil {
+		os.Exit(reportError(cmd.Name, tagged(ErrInvalidInput, err), *errorFormat))
+	}
 
-	default:
-		logger.Println("unknown command", "command", os.Args[1])
-		logger.Println("Available commands: solve, scan")
-		return
+	err = cmd.Run(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(EXIT_OK)
+	}
+	if err != nil {
+		os.Exit(reportError(cmd.Name, err, *errorFormat))
 	}
 
 	elapsed := time.Since(start)
 	runtime.ReadMemStats(&mEnd)
-	usedMemory := (mEnd.Alloc - mStart.Alloc) / KILO
-	logger.Println("memory usage", "used", usedMemory)
-	logger.Println("execution time", "duration", elapsed)
+	// Signed difference: with streaming, the heap may shrink after a GC
+	usedMemory := (int64(mEnd.Alloc) - int64(mStart.Alloc)) / KILO
+	logger.Info("Memory usage", "used_kib", usedMemory)
+	logger.Info("Execution time", "duration", elapsed)
 }
-
-
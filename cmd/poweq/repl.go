@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/peterh/liner"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/plot"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+const REPL_PROMPT = "poweq> "
+
+// Name of the REPL history file, in the home directory
+const REPL_HISTORY = ".poweq_history"
+
+// METHOD_ALL solves with every method in the REPL
+const METHOD_ALL = "all"
+
+// replParam is a parameter of the session, changed with set.
+type replParam struct {
+	Name string
+	get  func(s *session) string
+	set  func(s *session, value string) error
+}
+
+func floatParam(name string, field func(job *solver.Job) *float64) replParam {
+	return replParam{name,
+		func(s *session) string { return solver.FormatFloat(*field(&s.Job), solver.DIGITS_EXACT) },
+		func(s *session, value string) error {
+			x, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", value)
+			}
+			*field(&s.Job) = x
+			return nil
+		}}
+}
+
+func intParam(name string, field func(s *session) *int) replParam {
+	return replParam{name,
+		func(s *session) string { return strconv.Itoa(*field(s)) },
+		func(s *session, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", value)
+			}
+			*field(s) = n
+			return nil
+		}}
+}
+
+var replParams = []replParam{
+	floatParam("n", func(job *solver.Job) *float64 { return &job.N }),
+	floatParam("m", func(job *solver.Job) *float64 { return &job.M }),
+	floatParam("K", func(job *solver.Job) *float64 { return &job.K }),
+	floatParam("a", func(job *solver.Job) *float64 { return &job.A }),
+	floatParam("b", func(job *solver.Job) *float64 { return &job.B }),
+	floatParam("tol", func(job *solver.Job) *float64 { return &job.Tol }),
+	intParam("maxIter", func(s *session) *int { return &s.Job.MaxIter }),
+	{"alg",
+		func(s *session) string { return s.Algorithm },
+		func(s *session, value string) error {
+			if !solver.IsMethod(value) {
+				return fmt.Errorf("unknown algorithm %q, expected one of %s", value, strings.Join(solver.Methods, ", "))
+			}
+			s.Algorithm = value
+			return nil
+		}},
+	intParam("precision", func(s *session) *int { return &s.Job.Precision }),
+	{"criterion",
+		func(s *session) string { return s.Job.Criterion },
+		func(s *session, value string) error {
+			if value == "default" {
+				value = solver.CRITERION_DEFAULT
+			}
+			if !solver.IsCriterion(value) {
+				return fmt.Errorf("unknown stopping criterion %q", value)
+			}
+			s.Job.Criterion = value
+			return nil
+		}},
+	{"timeout",
+		func(s *session) string { return s.Job.Timeout.String() },
+		func(s *session, value string) (err error) {
+			s.Job.Timeout, err = jobfile.ParseTimeout(value)
+			return err
+		}},
+	{"digits",
+		func(s *session) string { return strconv.Itoa(s.Digits) },
+		func(s *session, value string) error {
+			digits, err := strconv.Atoi(value)
+			if err == nil {
+				err = solver.CheckDigits(digits)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid digits %q: %w", value, err)
+			}
+			s.Digits = digits
+			return nil
+		}},
+}
+
+func paramNames() []string {
+	names := make([]string, len(replParams))
+	for i, p := range replParams {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// findParam looks a parameter up whatever its case, K being the only
+// upper case one.
+func findParam(name string) (replParam, bool) {
+	i := slices.IndexFunc(replParams, func(p replParam) bool { return strings.EqualFold(p.Name, name) })
+	if i < 0 {
+		return replParam{}, false
+	}
+	return replParams[i], true
+}
+
+// session is the state of the REPL: the current job and the jobs solved
+// so far, which save writes out.
+type session struct {
+	Job       solver.Job
+	Algorithm string
+	Digits    int
+	Trace     bool
+	Solved    []jobfile.Record
+
+	out     io.Writer
+	handler slog.Handler // Solver log handler, restored when tracing stops
+}
+
+// newSession starts from the defaults of solve.
+func newSession(out io.Writer) *session {
+	s := &session{out: out}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	s.Job = solver.Job{N: 1, M: 2.718281828, K: 1, A: 1e-6, B: 1e6,
+		Tol: conf.Solver.Tolerance, MaxIter: conf.Solver.MaxIter,
+		Precision: conf.Solver.Precision, Criterion: conf.Solver.Criterion, Timeout: conf.Solver.Timeout}
+	s.Algorithm = conf.Solver.Algorithm
+	s.Digits = conf.Output.Digits
+}
+
+func (s *session) format(x float64) string {
+	return solver.FormatFloat(x, s.Digits)
+}
+
+// replAction is a command of the REPL.
+type replAction struct {
+	Name, Args, Doc string
+	run             func(s *session, args []string) error
+}
+
+// replActions is filled in init, as help refers back to it.
+var replActions []replAction
+
+func init() {
+	replActions = []replAction{
+		{"set", "<param> <value> ...", "Set parameters: " + strings.Join(paramNames(), ", "), (*session).set},
+		{"show", "", "Show the parameters", (*session).show},
+		{"solve", "[method | all]", "Solve with the algorithm, another method or all of them, listing every root and failure", (*session).solve},
+		{"roots", "", "Show the closed-form roots in [a, b]", (*session).roots},
+		{"analyze", "", "Explain the job: x_limit, expected roots, initial guesses and closed form, as explain does", (*session).analyze},
+		{"plot", "[from to]", "Plot f(x) in ASCII over [a, b] or [from, to]", (*session).plotJob},
+		{"trace", "[on | off]", "Toggle the tracing of every iteration", (*session).trace},
+		{"jobs", "", "List the jobs solved in this session", (*session).jobs},
+		{"save", "<file>", "Save the jobs solved in this session to a CSV file for scan", (*session).save},
+		{"reset", "", "Restore the default parameters", func(s *session, args []string) error { s.reset(); return nil }},
+		{"help", "", "List the commands", (*session).help},
+		{"quit", "", "Leave, as exit and Ctrl-D do", nil},
+	}
+}
+
+func actionNames() []string {
+	names := make([]string, len(replActions))
+	for i, a := range replActions {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// prompter reads the lines of the REPL, liner.State in a terminal.
+type prompter interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+}
+
+// run reads and executes commands until quit or end of input. Errors of
+// a command are printed, only a failure to read stops the session.
+func (s *session) run(line prompter) error {
+	defer s.setTrace(false)
+	fmt.Fprintln(s.out, "Solving x^n = K m^x interactively, type help for the commands")
+	for {
+		input, err := line.Prompt(REPL_PROMPT)
+		if err == io.EOF {
+			fmt.Fprintln(s.out)
+			return nil
+		}
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue // Ctrl-C clears the line
+		}
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			continue
+		}
+		line.AppendHistory(input)
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return nil
+		}
+		if err := s.exec(fields); err != nil {
+			fmt.Fprintln(s.out, "error:", err)
+		}
+	}
+}
+
+func (s *session) exec(fields []string) error {
+	i := slices.IndexFunc(replActions, func(a replAction) bool { return a.Name == fields[0] })
+	if i < 0 {
+		return fmt.Errorf("unknown command %q, type help for the list", fields[0])
+	}
+	return replActions[i].run(s, fields[1:])
+}
+
+// complete proposes the commands, then the parameters of set, the
+// methods of solve and the states of trace.
+func (s *session) complete(line string) []string {
+	head, word := "", line
+	if i := strings.LastIndex(line, " "); i >= 0 {
+		head, word = line[:i+1], line[i+1:]
+	}
+	fields := strings.Fields(head)
+
+	var candidates []string
+	switch {
+	case len(fields) == 0:
+		candidates = append(actionNames(), "exit")
+	case fields[0] == "set" && len(fields)%2 == 1:
+		candidates = paramNames()
+	case fields[0] == "solve" && len(fields) == 1:
+		candidates = append(slices.Clone(solver.Methods), METHOD_ALL)
+	case fields[0] == "trace" && len(fields) == 1:
+		candidates = []string{"on", "off"}
+	}
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			completions = append(completions, head+candidate)
+		}
+	}
+	return completions
+}
+
+func (s *session) help(args []string) error {
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	for _, a := range replActions {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", a.Name, a.Args, a.Doc)
+	}
+	return tw.Flush()
+}
+
+func (s *session) set(args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errors.New("expected pairs of parameter and value, e.g. set n 2.5 m 3")
+	}
+	for i := 0; i < len(args); i += 2 {
+		param, ok := findParam(args[i])
+		if !ok {
+			return fmt.Errorf("unknown parameter %q, expected one of %s", args[i], strings.Join(paramNames(), ", "))
+		}
+		if err := param.set(s, args[i+1]); err != nil {
+			return fmt.Errorf("%s: %w", param.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *session) show(args []string) error {
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	for _, param := range replParams {
+		fmt.Fprintf(tw, "  %s\t%s\n", param.Name, param.get(s))
+	}
+	return tw.Flush()
+}
+
+func (s *session) solve(args []string) error {
+	if len(args) > 1 {
+		return errors.New("expected at most one method")
+	}
+	methods := []string{s.Algorithm}
+	if len(args) == 1 {
+		switch {
+		case args[0] == METHOD_ALL:
+			methods = solver.Methods
+		case solver.IsMethod(args[0]):
+			methods = args[:1]
+		default:
+			return fmt.Errorf("unknown method %q, expected one of %s or %s", args[0], strings.Join(solver.Methods, ", "), METHOD_ALL)
+		}
+	}
+	if err := s.Job.Validate(); err != nil {
+		return err
+	}
+
+	job := s.Job
+	job.Id = len(s.Solved) + 1
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  ALGORITHM\tX\tSTEPS\tMETHOD\tERROR")
+	for _, method := range methods {
+		for _, result := range job.Process(method) {
+			x, errText := s.format(result.X), ""
+			if result.Err != nil {
+				x, errText = "-", result.Err.Error()
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\t%s\n", method, x, result.Steps, result.Method, errText)
+		}
+	}
+	algorithm := s.Algorithm
+	if len(methods) == 1 {
+		algorithm = methods[0]
+	}
+	s.Solved = append(s.Solved, jobfile.Record{Job: job, Algorithm: algorithm, RawId: strconv.Itoa(job.Id)})
+	return tw.Flush()
+}
+
+func (s *session) roots(args []string) error {
+	roots := s.Job.ExactRoots()
+	if len(roots) == 0 {
+		fmt.Fprintln(s.out, "  No root in [a, b]")
+		return nil
+	}
+	for i, x := range roots {
+		fmt.Fprintf(s.out, "  x%d = %s\n", i+1, s.format(x))
+	}
+	return nil
+}
+
+func (s *session) analyze(args []string) error {
+	if err := s.Job.Validate(); err != nil {
+		return err
+	}
+	return writeExplanation(s.out, s.Job, s.Job.Explain(), s.Digits)
+}
+
+func (s *session) plotJob(args []string) error {
+	from, to := s.Job.A, s.Job.B
+	switch len(args) {
+	case 0:
+	case 2:
+		var err error
+		if from, err = strconv.ParseFloat(args[0], 64); err != nil {
+			return fmt.Errorf("invalid number %q", args[0])
+		}
+		if to, err = strconv.ParseFloat(args[1], 64); err != nil {
+			return fmt.Errorf("invalid number %q", args[1])
+		}
+	default:
+		return errors.New("expected no range or from and to")
+	}
+	p, err := plot.New(s.Job, from, to)
+	if err != nil {
+		return err
+	}
+	return p.ASCII(s.out, plot.ASCII_WIDTH, plot.ASCII_HEIGHT)
+}
+
+func (s *session) trace(args []string) error {
+	switch {
+	case len(args) == 0:
+		s.setTrace(!s.Trace)
+	case args[0] == "on":
+		s.setTrace(true)
+	case args[0] == "off":
+		s.setTrace(false)
+	default:
+		return fmt.Errorf("expected on or off, not %q", args[0])
+	}
+	state := "off"
+	if s.Trace {
+		state = "on"
+	}
+	fmt.Fprintln(s.out, "  Tracing", state)
+	return nil
+}
+
+// setTrace sends the iterations of the solver to the output of the
+// session, without the time and level of the records.
+func (s *session) setTrace(on bool) {
+	if on == s.Trace {
+		return
+	}
+	s.Trace = on
+	if !on {
+		solver.SetLogHandler(s.handler)
+		return
+	}
+	s.handler = solver.LogHandler()
+	solver.SetLogHandler(slog.NewTextHandler(s.out, &slog.HandlerOptions{
+		Level: solver.LEVEL_TRACE,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func (s *session) jobs(args []string) error {
+	if len(s.Solved) == 0 {
+		fmt.Fprintln(s.out, "  No job solved yet")
+		return nil
+	}
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  "+strings.Join(jobfile.RequiredColumns, "\t")+"\t"+jobfile.COL_ALGORITHM)
+	for _, rec := range s.Solved {
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.Join(jobfile.JobCells(rec)[:len(jobfile.RequiredColumns)], "\t"), rec.Algorithm)
+	}
+	return tw.Flush()
+}
+
+// save writes the solved jobs with every job column, so that scan solves
+// them again with the same settings.
+func (s *session) save(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a file name")
+	}
+	if len(s.Solved) == 0 {
+		return errors.New("no job solved yet")
+	}
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write(append(slices.Clone(jobfile.RequiredColumns), jobfile.OptionalColumns...))
+	for _, rec := range s.Solved {
+		writer.Write(jobfile.JobCells(rec))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "  Saved %d jobs to %s\n", len(s.Solved), args[0])
+	return file.Close()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Number of jobs listed in the slowest jobs of a report
+const SLOWEST_JOBS = 5
+
+// scanReport sums up a scan, for tracking solver regressions across
+// releases. It is saved with the checkpoints so that a resumed scan
+// reports on the whole input.
+type scanReport struct {
+	Jobs     int            `json:"jobs"`     // Input rows, rejected ones included
+	Solved   int            `json:"solved"`   // Jobs with at least one root
+	Failed   int            `json:"failed"`   // Valid jobs without any root
+	Rejected int            `json:"rejected"` // Rows that could not be turned into jobs
+	Errors   map[string]int `json:"errors"`   // Failed jobs by error
+	Roots    map[int]int    `json:"roots"`    // Valid jobs by number of roots found
+
+	// Steps by method of the roots found. Steps are bounded by MaxIter,
+	// so a histogram keeps memory bounded whatever the number of jobs.
+	Steps map[string]*stepStats `json:"steps"`
+
+	// Jobs solved with "auto" and, among them, those where Newton found
+	// nothing and bisection took over
+	AutoJobs      int     `json:"auto_jobs"`
+	AutoFallbacks int     `json:"auto_fallbacks"`
+	FallbackRate  float64 `json:"fallback_rate"`
+
+	Slowest []slowJob `json:"slowest"`
+}
+
+type stepStats struct {
+	Roots     int         `json:"roots"`
+	Mean      float64     `json:"mean"`
+	Median    int         `json:"median"`
+	P99       int         `json:"p99"`
+	Histogram map[int]int `json:"histogram"` // Number of roots by steps taken
+}
+
+type slowJob struct {
+	Id        string        `json:"id"`
+	Line      int           `json:"line"`
+	Algorithm string        `json:"algorithm"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+}
+
+func newScanReport() *scanReport {
+	return &scanReport{
+		Errors: map[string]int{},
+		Roots:  map[int]int{},
+		Steps:  map[string]*stepStats{},
+	}
+}
+
+// Add accounts for one input row and its results.
+func (r *scanReport) Add(rec jobfile.Record, results []solver.Result) {
+	r.Jobs++
+	if rec.Err != nil {
+		r.Rejected++
+		return
+	}
+
+	roots := 0
+	fallback := false
+	var firstErr error
+	for _, result := range results {
+		if result.Err != nil {
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			continue
+		}
+		roots++
+		fallback = fallback || result.Method == solver.METHOD_BISECTION
+		stats := r.Steps[result.Method]
+		if stats == nil {
+			stats = &stepStats{Histogram: map[int]int{}}
+			r.Steps[result.Method] = stats
+		}
+		stats.Roots++
+		stats.Histogram[result.Steps]++
+	}
+	r.Roots[roots]++
+	if roots > 0 {
+		r.Solved++
+	} else {
+		r.Failed++
+		if firstErr == nil {
+			firstErr = solver.ErrNoSolutionsFound
+		}
+		r.Errors[firstErr.Error()]++
+	}
+	if rec.Algorithm == solver.METHOD_AUTO {
+		r.AutoJobs++
+		if fallback {
+			r.AutoFallbacks++
+		}
+	}
+	r.addSlow(slowJob{Id: rec.RawId, Line: rec.Line, Algorithm: rec.Algorithm, Elapsed: rec.Elapsed})
+}
+
+// addSlow keeps the SLOWEST_JOBS slowest jobs, slowest first.
+func (r *scanReport) addSlow(job slowJob) {
+	i, _ := slices.BinarySearchFunc(r.Slowest, job, func(a, b slowJob) int {
+		return cmp.Compare(b.Elapsed, a.Elapsed)
+	})
+	if i >= SLOWEST_JOBS {
+		return
+	}
+	r.Slowest = slices.Insert(r.Slowest, i, job)
+	if len(r.Slowest) > SLOWEST_JOBS {
+		r.Slowest = r.Slowest[:SLOWEST_JOBS]
+	}
+}
+
+// Finish computes the derived figures from the counters.
+func (r *scanReport) Finish() {
+	if r.AutoJobs > 0 {
+		r.FallbackRate = float64(r.AutoFallbacks) / float64(r.AutoJobs)
+	}
+	for _, stats := range r.Steps {
+		stats.finish()
+	}
+}
+
+func (s *stepStats) finish() {
+	if s.Roots == 0 {
+		return
+	}
+	total := 0
+	for steps, count := range s.Histogram {
+		total += steps * count
+	}
+	s.Mean = float64(total) / float64(s.Roots)
+	s.Median = s.percentile(0.5)
+	s.P99 = s.percentile(0.99)
+}
+
+// percentile uses the nearest rank method.
+func (s *stepStats) percentile(p float64) int {
+	rank := int(math.Ceil(p * float64(s.Roots)))
+	seen := 0
+	for _, steps := range slices.Sorted(maps.Keys(s.Histogram)) {
+		if seen += s.Histogram[steps]; seen >= rank {
+			return steps
+		}
+	}
+	return 0
+}
+
+// WriteText prints the report for a terminal.
+func (r *scanReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Jobs\t%d\n", r.Jobs)
+	fmt.Fprintf(tw, "Solved\t%d\n", r.Solved)
+	fmt.Fprintf(tw, "Failed\t%d\n", r.Failed)
+	fmt.Fprintf(tw, "Rejected\t%d\n", r.Rejected)
+	for _, msg := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(tw, "  %s\t%d\n", msg, r.Errors[msg])
+	}
+	for _, roots := range slices.Sorted(maps.Keys(r.Roots)) {
+		fmt.Fprintf(tw, "Jobs with %d roots\t%d\n", roots, r.Roots[roots])
+	}
+	if r.AutoJobs > 0 {
+		fmt.Fprintf(tw, "Auto fallbacks\t%d/%d (%.1f%%)\n", r.AutoFallbacks, r.AutoJobs, 100*r.FallbackRate)
+	}
+	if len(r.Steps) > 0 {
+		fmt.Fprintln(tw, "\nMETHOD\tROOTS\tMEAN STEPS\tMEDIAN\tP99")
+		for _, method := range slices.Sorted(maps.Keys(r.Steps)) {
+			s := r.Steps[method]
+			fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%d\n", method, s.Roots, s.Mean, s.Median, s.P99)
+		}
+	}
+	if len(r.Slowest) > 0 {
+		fmt.Fprintln(tw, "\nSLOWEST\tLINE\tALGORITHM\tTIME")
+		for _, job := range r.Slowest {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%v\n", job.Id, job.Line, job.Algorithm, job.Elapsed)
+		}
+	}
+	return tw.Flush()
+}
+
+// Save writes the report as JSON to path.
+func (r *scanReport) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// scanConfig holds the settings of a scan run, as parsed from the flags.
+type scanConfig struct {
+	InFile     string // STDIO for stdin
+	OutFile    string // STDIO for stdout
+	InFormat   string
+	OutFormat  string
+	RejectsOut string // Empty disables the rejects file
+	CSV        jobfile.Options
+	Strict     bool
+	MaxRejects int // 0 for no limit
+	Workers    int
+	Checkpoint int // Rows between checkpoints, 0 disables them
+	Resume     bool
+	ReportOut  string // JSON summary report, empty to only print it
+
+	Cache  *solver.Cache // Results of jobs already solved, nil to solve every job
+	Atomic bool          // Write to temporary files renamed once complete
+}
+
+// scanState is the sidecar file written next to the output at each
+// checkpoint. Results are written in input order, so a row count and the
+// output sizes are enough to pick a run up where it stopped.
+type scanState struct {
+	InFile        string `json:"in_file"`
+	Rows          int    `json:"rows"`    // Input rows processed, rejected ones included
+	LastId        string `json:"last_id"` // Raw Id of the last processed row
+	ResultsOffset int64  `json:"results_offset"`
+	RejectsOffset int64  `json:"rejects_offset"`
+	NbResults     int    `json:"results"`
+	NbRejected    int    `json:"rejected"`
+	Complete      bool   `json:"complete"`
+
+	Report *scanReport `json:"report,omitempty"`
+}
+
+func statePath(outFile string) string {
+	return outFile + ".state"
+}
+
+// loadState reads a checkpoint, returning nil if there is none.
+func loadState(path string) (*scanState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state scanState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// save replaces the checkpoint atomically so that a crash while saving
+// leaves the previous one intact.
+func (state scanState) save(path string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openOutput creates path, or on resume truncates it back to the size
+// recorded by the last checkpoint and positions at its end.
+func openOutput(path string, resume bool, offset int64) (*os.File, error) {
+	if !resume {
+		return os.Create(path)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// runScan streams the jobs of cfg.InFile through the solver into cfg.OutFile.
+func runScan(cfg scanConfig) (solver.Batch, error) {
+	batch := solver.Batch{InFile: cfg.InFile, OutFile: cfg.OutFile}
+
+	// Checkpoints need files that can be read again and truncated
+	if cfg.InFile == STDIO || cfg.OutFile == STDIO {
+		if cfg.Resume {
+			return batch, usageErrorf("cannot resume a scan reading stdin or writing stdout")
+		}
+		cfg.Checkpoint = 0
+	}
+	// Arrow and Parquet files end with a footer, they cannot be appended to
+	if cfg.OutFormat == FORMAT_ARROW || cfg.OutFormat == FORMAT_PARQUET {
+		if cfg.Resume {
+			return batch, usageErrorf("cannot resume a scan writing %s output", cfg.OutFormat)
+		}
+		cfg.Checkpoint = 0
+	}
+
+	var state *scanState
+	if cfg.Resume {
+		var err error
+		if state, err = loadState(statePath(cfg.OutFile)); err != nil {
+			logger.Error("Error reading checkpoint", "error", err)
+			return batch, err
+		}
+		if state == nil {
+			logger.Info("No checkpoint found, starting from scratch", "file", statePath(cfg.OutFile))
+		} else if state.InFile != cfg.InFile {
+			return batch, usageErrorf("checkpoint was written for input %s, not %s", state.InFile, cfg.InFile)
+		}
+	}
+	resume := state != nil
+	if resume {
+		batch.NbJobs, batch.NbResults, batch.NbRejected = state.Rows, state.NbResults, state.NbRejected
+		if state.Complete {
+			logger.Info("Scan already complete, nothing to resume", "jobs", batch.NbJobs)
+			if state.Report != nil {
+				return batch, writeReport(state.Report, cfg.ReportOut)
+			}
+			return batch, nil
+		}
+	} else if cfg.OutFile != STDIO {
+		// A stale checkpoint would not match the output written from now on
+		if err := os.Remove(statePath(cfg.OutFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return batch, err
+		}
+	}
+	if state == nil {
+		state = &scanState{InFile: cfg.InFile}
+	}
+	// Checkpoints from older versions have no report
+	if state.Report == nil {
+		state.Report = newScanReport()
+	}
+	report := state.Report
+
+	// Open files
+	inFile, err := openInput(cfg.InFile)
+	if err != nil {
+		logger.Error("Error opening input file", "error", err)
+		return batch, err
+	}
+	defer inFile.Close()
+
+	// The header is checked before the output file is touched
+	reader, err := jobfile.NewReader(inFile, cfg.InFormat, cfg.CSV)
+	if err != nil {
+		logger.Error("Error reading jobs from input file", "error", err)
+		return batch, tagged(ErrInvalidInput, err)
+	}
+	// Taken before skipping, JSON readers take them from the first job
+	extraNames := reader.ExtraColumns()
+	if resume {
+		lastId, err := reader.Skip(state.Rows)
+		if err == nil && lastId != state.LastId {
+			err = fmt.Errorf("row %d has id %q, checkpoint expected %q", state.Rows, lastId, state.LastId)
+		}
+		if err != nil {
+			logger.Error("Input does not match the checkpoint", "error", err)
+			return batch, tagged(ErrInvalidInput, err)
+		}
+		logger.Info("Resuming scan", "skipped", state.Rows, "last_id", state.LastId)
+	}
+
+	// Atomic runs leave the previous output in place until they succeed
+	outPath, rejectsPath := cfg.OutFile, cfg.RejectsOut
+	if cfg.Atomic {
+		outPath = tempPath(outPath)
+		if rejectsPath != "" {
+			rejectsPath = tempPath(rejectsPath)
+		}
+	}
+
+	outFile := os.Stdout
+	if cfg.OutFile != STDIO {
+		if outFile, err = openOutput(outPath, resume, state.ResultsOffset); err != nil {
+			logger.Error("Error creating output file", "error", err)
+			return batch, err
+		}
+		defer outFile.Close()
+	}
+
+	var rejectsFile *os.File
+	var rejects *jobfile.RejectWriter
+	if cfg.RejectsOut != "" {
+		// A previous run may have had no rejects file at all
+		_, statErr := os.Stat(cfg.RejectsOut)
+		resumeRejects := resume && statErr == nil && state.RejectsOffset > 0
+		if rejectsFile, err = openOutput(rejectsPath, resumeRejects, state.RejectsOffset); err != nil {
+			logger.Error("Error creating rejects file", "error", err)
+			return batch, err
+		}
+		defer rejectsFile.Close()
+		rejects = jobfile.NewRejectWriter(rejectsFile, cfg.CSV)
+		if !resumeRejects {
+			if err := rejects.WriteHeader(); err != nil {
+				logger.Error("Error writing rejects file", "error", err)
+				return batch, err
+			}
+		}
+	}
+
+	// Jobs are read, solved and written one at a time so that memory
+	// stays bounded whatever the size of the input file
+	writer := jobfile.NewWriter(outFile, cfg.OutFormat, cfg.CSV, extraNames, state.Rows)
+	if !resume {
+		if err := writer.WriteHeader(); err != nil {
+			logger.Error("Error writing results to output file", "error", err)
+			return batch, err
+		}
+	}
+
+	// Checkpoints record how far the run got. Offsets are taken after the
+	// flush so they match the files.
+	checkpoint := func(counts jobfile.ScanCounts, complete bool) error {
+		if cfg.Checkpoint <= 0 {
+			return nil
+		}
+		state.Rows, state.NbResults, state.NbRejected = counts.Jobs, counts.Results, counts.Rejected
+		state.Complete = complete
+		var err error
+		if state.ResultsOffset, err = outFile.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+		if rejectsFile != nil {
+			if state.RejectsOffset, err = rejectsFile.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		return state.save(statePath(cfg.OutFile))
+	}
+
+	scanner := jobfile.Scanner{
+		Workers:    cfg.Workers,
+		Cache:      cfg.Cache,
+		Strict:     cfg.Strict,
+		MaxRejects: cfg.MaxRejects,
+		Rejects:    rejects,
+		FlushRows:  cfg.Checkpoint,
+		OnRow: func(rec jobfile.Record, results []solver.Result) {
+			if rec.Err != nil {
+				logger.Warn("Rejected record", "line", rec.Line, "error", rec.Err)
+			}
+			state.LastId = rec.RawId
+			report.Add(rec, results)
+		},
+		OnFlush: checkpoint,
+	}
+	counts := jobfile.ScanCounts{Jobs: batch.NbJobs, Results: batch.NbResults, Rejected: batch.NbRejected}
+	scanErr := scanner.Run(context.Background(), reader, writer, &counts)
+	batch.NbJobs, batch.NbResults, batch.NbRejected = counts.Jobs, counts.Results, counts.Rejected
+	if errors.Is(scanErr, jobfile.ErrRejected) {
+		scanErr = tagged(ErrInvalidInput, scanErr)
+	} else if scanErr != nil {
+		logger.Error("Error scanning jobs", "error", scanErr)
+	}
+	// Closing comes after the checkpoint: what it writes is dropped on resume
+	if err := writer.Close(); err != nil && scanErr == nil {
+		logger.Error("Error writing results to output file", "error", err)
+		scanErr = err
+	}
+	if cfg.Atomic {
+		if err := commitOutputs(scanErr, outFile, outPath, cfg.OutFile, rejectsFile, rejectsPath, cfg.RejectsOut); err != nil && scanErr == nil {
+			logger.Error("Error replacing output file", "error", err)
+			scanErr = err
+		}
+	}
+
+	logger.Info("Scan completed", "jobs", batch.NbJobs, "results", batch.NbResults, "rejected", batch.NbRejected)
+	if batch.NbRejected > 0 && rejects != nil {
+		logger.Info("Rejected rows written", "file", cfg.RejectsOut)
+	}
+	if err := writeReport(report, cfg.ReportOut); err != nil && scanErr == nil {
+		scanErr = err
+	}
+	return batch, scanErr
+}
+
+func tempPath(path string) string {
+	return path + ".tmp"
+}
+
+// commitOutputs closes the temporary files of an atomic run and renames
+// them over the final ones, or removes them when the run failed.
+func commitOutputs(scanErr error, outFile *os.File, outTemp, outPath string, rejectsFile *os.File, rejectsTemp, rejectsPath string) error {
+	files := []*os.File{outFile, rejectsFile}
+	temps := []string{outTemp, rejectsTemp}
+	paths := []string{outPath, rejectsPath}
+	for i, file := range files {
+		if file == nil {
+			continue
+		}
+		if err := file.Close(); err != nil && scanErr == nil {
+			scanErr = err
+		}
+		if scanErr == nil {
+			scanErr = os.Rename(temps[i], paths[i])
+		}
+		if scanErr != nil {
+			os.Remove(temps[i])
+		}
+	}
+	return scanErr
+}
+
+// writeReport prints the summary of a scan on stderr, stdout being
+// possibly the results, and saves it as JSON when path is set.
+func writeReport(report *scanReport, path string) error {
+	report.Finish()
+	if err := report.WriteText(os.Stderr); err != nil {
+		return err
+	}
+	if path == "" {
+		return nil
+	}
+	if err := report.Save(path); err != nil {
+		logger.Error("Error writing report", "error", err)
+		return err
+	}
+	logger.Info("Report written", "file", path)
+	return nil
+}
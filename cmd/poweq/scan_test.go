@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/config"
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+)
+
+// quiet silences the logs and the reports printed on stderr.
+func quiet(t *testing.T) {
+	t.Helper()
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, log := os.Stderr, logger
+	os.Stderr, logger = null, slog.New(slog.NewTextHandler(io.Discard, nil))
+	t.Cleanup(func() {
+		os.Stderr, logger = stderr, log
+		null.Close()
+	})
+}
+
+// defaultConfig gives the commands the default configuration for the
+// length of the test.
+func defaultConfig(t *testing.T) {
+	t.Helper()
+	saved := conf
+	t.Cleanup(func() { conf = saved })
+	var err error
+	if conf, err = config.Load("", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestJobs writes a jobs file of n rows, every rejectEvery-th one
+// rejected, with a passthrough column.
+func writeTestJobs(t *testing.T, path string, n, rejectEvery int) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("Id,N,M,K,A,B,tag\n")
+	for id := 1; id <= n; id++ {
+		power := "2"
+		if id%rejectEvery == 0 {
+			power = "x"
+		}
+		fmt.Fprintf(&b, "%d,%s,%d,%d,0.%d,%d,row%d\n", id, power, id%3+1, id%5+1, id%9+1, 10*id, id)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestScanResume(t *testing.T) {
+	quiet(t)
+	tests := []struct {
+		name       string
+		format     string
+		workers    int
+		checkpoint int
+		interrupt  func(cfg *scanConfig) // Makes the first run stop early
+	}{
+		{"csv strict", FORMAT_CSV, 1, 10, func(cfg *scanConfig) { cfg.Strict = true }},
+		{"csv max rejects", FORMAT_CSV, 4, 7, func(cfg *scanConfig) { cfg.MaxRejects = 2 }},
+		{"ndjson strict", FORMAT_NDJSON, 4, 10, func(cfg *scanConfig) { cfg.Strict = true }},
+		{"json max rejects", FORMAT_JSON, 2, 5, func(cfg *scanConfig) { cfg.MaxRejects = 3 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			in := filepath.Join(dir, "jobs.csv")
+			writeTestJobs(t, in, 120, 13)
+			config := func(name string) scanConfig {
+				return scanConfig{
+					InFile: in, OutFile: filepath.Join(dir, name+"."+tt.format),
+					RejectsOut: filepath.Join(dir, name+".rejects.csv"),
+					InFormat:   FORMAT_CSV, OutFormat: tt.format,
+					CSV:     jobfile.Options{Delimiter: ',', Defaults: jobfile.Defaults{Tol: 1e-9, MaxIter: 100, Algorithm: "auto"}},
+					Workers: tt.workers, Checkpoint: tt.checkpoint,
+				}
+			}
+
+			full := config("full")
+			want, err := runScan(full)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := config("resumed")
+			tt.interrupt(&cfg)
+			stopped, err := runScan(cfg)
+			if err == nil {
+				t.Fatal("first run was not interrupted")
+			}
+			if stopped.NbJobs >= want.NbJobs {
+				t.Fatalf("first run processed %d jobs of %d", stopped.NbJobs, want.NbJobs)
+			}
+			cfg = config("resumed")
+			cfg.Resume = true
+			got, err := runScan(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.NbJobs != want.NbJobs || got.NbResults != want.NbResults || got.NbRejected != want.NbRejected {
+				t.Errorf("resumed counts = %d/%d/%d, want %d/%d/%d",
+					got.NbJobs, got.NbResults, got.NbRejected, want.NbJobs, want.NbResults, want.NbRejected)
+			}
+			if readFile(t, cfg.OutFile) != readFile(t, full.OutFile) {
+				t.Error("resumed results differ from an uninterrupted run")
+			}
+			if readFile(t, cfg.RejectsOut) != readFile(t, full.RejectsOut) {
+				t.Error("resumed rejects differ from an uninterrupted run")
+			}
+
+			// A complete run is not resumed again
+			again, err := runScan(cfg)
+			if err != nil || again.NbJobs != want.NbJobs {
+				t.Errorf("resuming a complete run = %d jobs, %v", again.NbJobs, err)
+			}
+		})
+	}
+}
+
+func TestScanRejects(t *testing.T) {
+	quiet(t)
+	tests := []struct {
+		name       string
+		configure  func(cfg *scanConfig)
+		jobs       int   // Rows processed, rejected ones included
+		rejectedAt []int // Ids of the rows in the rejects file
+		stopped    bool
+	}{
+		{"kept", func(cfg *scanConfig) {}, 30, []int{7, 14, 21, 28}, false},
+		{"strict", func(cfg *scanConfig) { cfg.Strict = true }, 6, nil, true},
+		{"max rejects", func(cfg *scanConfig) { cfg.MaxRejects = 2 }, 20, []int{7, 14}, true},
+		{"max rejects not reached", func(cfg *scanConfig) { cfg.MaxRejects = 4 }, 30, []int{7, 14, 21, 28}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := scanConfig{
+				InFile: filepath.Join(dir, "jobs.csv"), OutFile: filepath.Join(dir, "results.csv"),
+				RejectsOut: filepath.Join(dir, "rejects.csv"),
+				InFormat:   FORMAT_CSV, OutFormat: FORMAT_CSV,
+				CSV:     jobfile.Options{Delimiter: ',', Defaults: jobfile.Defaults{Tol: 1e-9, MaxIter: 100, Algorithm: "auto"}},
+				Workers: 3,
+			}
+			writeTestJobs(t, cfg.InFile, 30, 7)
+			tt.configure(&cfg)
+
+			batch, err := runScan(cfg)
+			if tt.stopped != (err != nil) {
+				t.Fatalf("runScan() error = %v, want stopped %v", err, tt.stopped)
+			}
+			if err != nil {
+				if code, _ := exitCode(err); !errors.Is(err, jobfile.ErrRejected) || code != EXIT_INVALID {
+					t.Errorf("runScan() error = %v, exit code %d, want a rejected row", err, code)
+				}
+			}
+			if batch.NbJobs != tt.jobs || batch.NbRejected != len(tt.rejectedAt) {
+				t.Errorf("%d jobs, %d rejected, want %d and %d", batch.NbJobs, batch.NbRejected, tt.jobs, len(tt.rejectedAt))
+			}
+
+			// The row that stops the scan is not written
+			results := readFile(t, cfg.OutFile)
+			if !strings.Contains(results, fmt.Sprintf(",row%d\n", tt.jobs)) || strings.Contains(results, fmt.Sprintf(",row%d\n", tt.jobs+1)) {
+				t.Errorf("results do not stop at row %d:\n%s", tt.jobs, results)
+			}
+
+			rows, err := csv.NewReader(strings.NewReader(readFile(t, cfg.RejectsOut))).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.rejectedAt)+1 || !slices.Equal(rows[0], []string{"Line", "Record", "Reason"}) {
+				t.Fatalf("rejects file = %q, want a header and %d rows", rows, len(tt.rejectedAt))
+			}
+			for i, id := range tt.rejectedAt {
+				line, record, reason := rows[i+1][0], rows[i+1][1], rows[i+1][2]
+				if line != strconv.Itoa(id+1) || !strings.HasPrefix(record, fmt.Sprintf("%d,x,", id)) || reason == "" {
+					t.Errorf("reject %d = %q, want line %d of the input", i+1, rows[i+1], id+1)
+				}
+			}
+		})
+	}
+}
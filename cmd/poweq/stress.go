@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"iter"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Generation modes
+const (
+	MODE_RANDOM = "random" // Parameters drawn from the distributions
+	MODE_STRESS = "stress" // Edge-case families with reference roots
+)
+
+// Name of the family of jobs that were not generated in stress mode
+const FAMILY_NONE = "-"
+
+// stressFamily is a kind of job the iterative methods are known to
+// struggle with. Parameters are drawn so that the closed form gives the
+// reference roots.
+type stressFamily struct {
+	Name string
+	Doc  string
+	draw func(rng *rand.Rand) solver.Job
+}
+
+func uniform(rng *rand.Rand, min, max float64) float64 {
+	return paramDist{DIST_UNIFORM, min, max}.draw(rng)
+}
+
+func logUniform(rng *rand.Rand, min, max float64) float64 {
+	return paramDist{DIST_LOGUNIFORM, min, max}.draw(rng)
+}
+
+// nearTop draws a job whose two roots surround the top of f, which lies
+// gap above zero.
+func nearTop(minGap, maxGap float64) func(rng *rand.Rand) solver.Job {
+	return func(rng *rand.Rand) solver.Job {
+		job := solver.Job{N: uniform(rng, 0.5, 10), M: uniform(rng, 1.1, 6), A: 1e-6, B: 1e6}
+		setTop(&job, logUniform(rng, minGap, maxGap))
+		return job
+	}
+}
+
+var stressFamilies = []stressFamily{
+	{"near-limit", "Roots close to x_limit, where f' vanishes", nearTop(1e-4, 1e-1)},
+	{"tangent", "Near-tangent double roots", nearTop(1e-12, 1e-6)},
+	{"n-zero", "n = 0, solved in closed form", func(rng *rand.Rand) solver.Job {
+		return solver.Job{N: 0, M: uniform(rng, 1.1, 6), K: logUniform(rng, 1e-6, 0.9), A: 1e-6, B: 1e3}
+	}},
+	{"m-one", "m = 1, solved in closed form", func(rng *rand.Rand) solver.Job {
+		return solver.Job{N: uniform(rng, 0.5, 10), M: 1, K: logUniform(rng, 1e-3, 1e3), A: 1e-6, B: 1e6}
+	}},
+	{"huge-b", "b around 1e6 and a far root, where Newton overshoots", func(rng *rand.Rand) solver.Job {
+		return solver.Job{N: uniform(rng, 10, 50), M: uniform(rng, 1.0001, 1.01), K: uniform(rng, 0.5, 2), A: 1e-6, B: uniform(rng, 5e5, 1e6)}
+	}},
+	{"tiny-k", "Tiny K, the small root goes to 0", func(rng *rand.Rand) solver.Job {
+		return solver.Job{N: uniform(rng, 0.5, 5), M: uniform(rng, 1.1, 6), K: logUniform(rng, 1e-300, 1e-12), A: 0, B: 1e6}
+	}},
+}
+
+func familyNames() []string {
+	names := make([]string, len(stressFamilies))
+	for i, family := range stressFamilies {
+		names[i] = family.Name
+	}
+	return names
+}
+
+// parseFamilies picks families by name, all of them for an empty list.
+func parseFamilies(spec string) ([]stressFamily, error) {
+	if spec == "" {
+		return stressFamilies, nil
+	}
+	var families []stressFamily
+	for _, name := range strings.Split(spec, ",") {
+		i := slices.IndexFunc(stressFamilies, func(f stressFamily) bool { return f.Name == strings.TrimSpace(name) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown family %q, expected one of %s", name, strings.Join(familyNames(), ", "))
+		}
+		families = append(families, stressFamilies[i])
+	}
+	return families, nil
+}
+
+// stressJobs yields count jobs numbered from 1, taking the families in
+// turn, with their reference roots. The same rng state always yields the
+// same jobs.
+func stressJobs(rng *rand.Rand, families []stressFamily, count int) iter.Seq2[genJob, error] {
+	return func(yield func(genJob, error) bool) {
+		for id := 1; id <= count; id++ {
+			family := families[(id-1)%len(families)]
+			job := family.draw(rng)
+			job.Id, job.Tol, job.MaxIter = id, DEFAULT_TOL, DEFAULT_MAX_ITER
+			if err := job.Validate(); err != nil {
+				yield(genJob{}, fmt.Errorf("job %d of family %s: %w", id, family.Name, err))
+				return
+			}
+			if !yield(genJob{Job: job, Family: family.Name, Roots: job.ExactRoots()}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// labeled reads back the family and the reference roots of a job. Jobs
+// that were not generated in stress mode get their roots from the
+// closed form.
+func labeled(rec jobfile.Record) (genJob, error) {
+	job := genJob{Job: rec.Job, Family: FAMILY_NONE}
+	if family, ok := rec.ExtraValue(COL_FAMILY); ok && family != "" {
+		job.Family = family
+	}
+	roots, ok := rec.ExtraValue(COL_ROOTS)
+	if !ok {
+		job.Roots = rec.Job.ExactRoots()
+		return job, nil
+	}
+	for _, field := range strings.Fields(roots) {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return job, fmt.Errorf("column %q: invalid root %q", COL_ROOTS, field)
+		}
+		job.Roots = append(job.Roots, x)
+	}
+	return job, nil
+}
+
+// checkStats counts, for one family and one method, the jobs whose roots
+// all match the reference.
+type checkStats struct {
+	Family, Method string
+	Jobs, Passed   int
+	Failed         []int // Ids of the failed jobs
+}
+
+// runCheck solves every job with every method and compares the roots
+// with the reference ones, by family.
+func runCheck(jobs iter.Seq2[genJob, error], methods []string, relTol float64) ([]*checkStats, error) {
+	var all []*checkStats
+	index := map[[2]string]*checkStats{}
+
+	for job, err := range jobs {
+		if err != nil {
+			return all, err
+		}
+		for _, method := range methods {
+			key := [2]string{job.Family, method}
+			stats := index[key]
+			if stats == nil {
+				stats = &checkStats{Family: job.Family, Method: method}
+				index[key] = stats
+				all = append(all, stats)
+			}
+			stats.Jobs++
+			if agree(roots(job.Process(method)), job.Roots, relTol) {
+				stats.Passed++
+			} else {
+				stats.Failed = append(stats.Failed, job.Id)
+			}
+		}
+	}
+	return all, nil
+}
+
+// MAX_FAILED_IDS bounds the ids of failed jobs listed per family and method
+const MAX_FAILED_IDS = 5
+
+func failedIds(stats *checkStats) string {
+	ids := make([]string, 0, MAX_FAILED_IDS+1)
+	for i, id := range stats.Failed {
+		if i == MAX_FAILED_IDS {
+			ids = append(ids, "...")
+			break
+		}
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return strings.Join(ids, " ")
+}
+
+func writeCheckTable(w io.Writer, all []*checkStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAMILY\tMETHOD\tJOBS\tPASSED\tFAILED\tPASS RATE\tFAILED IDS")
+	for _, s := range all {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%.1f%%\t%s\n",
+			s.Family, s.Method, s.Jobs, s.Passed, len(s.Failed), 100*ratio(s.Passed, s.Jobs), failedIds(s))
+	}
+	return tw.Flush()
+}
+
+func writeCheckCSV(w io.Writer, all []*checkStats) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Family", "Method", "Jobs", "Passed", "Failed", "PassRate"})
+	for _, s := range all {
+		writer.Write([]string{
+			s.Family, s.Method,
+			strconv.Itoa(s.Jobs), strconv.Itoa(s.Passed), strconv.Itoa(len(s.Failed)),
+			solver.FormatFloat(ratio(s.Passed, s.Jobs), solver.DIGITS_EXACT),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
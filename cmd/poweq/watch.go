@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// DEFAULT_WATCH_INTERVAL is how often scan -watch polls its input.
+const DEFAULT_WATCH_INTERVAL = time.Second
+
+// watchScan runs the scan each time its input changes, until ctx is
+// done. The input is polled for changes of its size or modification time.
+// Failed runs are logged and the watch goes on, the input being possibly
+// half edited.
+func watchScan(ctx context.Context, cfg scanConfig, interval time.Duration) error {
+	// Each run rewrites the whole output, solving only the jobs not in the
+	// cache, which is kept in memory when disabled
+	if cfg.Cache == nil {
+		var err error
+		if cfg.Cache, err = solver.NewCache(conf.Cache.Size, ""); err != nil {
+			return err
+		}
+	}
+	cfg.Atomic = true
+	cfg.Checkpoint = 0
+
+	logger.Info("Watching input", "file", cfg.InFile, "interval", interval)
+	var last os.FileInfo
+	var lastErr string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		info, err := os.Stat(cfg.InFile)
+		switch {
+		case err != nil:
+			if err.Error() != lastErr {
+				logger.Warn("Cannot read input, waiting for it", "error", err)
+			}
+			lastErr = err.Error()
+		case last == nil || info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()):
+			last, lastErr = info, ""
+			start, before := time.Now(), cfg.Cache.Stats()
+			batch, err := runScan(cfg)
+			after := cfg.Cache.Stats()
+			if err != nil {
+				logger.Error("Scan failed, waiting for the next change", "error", err)
+			}
+			logger.Info("Scan updated", "jobs", batch.NbJobs, "solved", after.Misses-before.Misses,
+				"unchanged", after.Hits-before.Hits, "duration", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Watch stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
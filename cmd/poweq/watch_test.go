@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/jobfile"
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+func TestWatchScan(t *testing.T) {
+	quiet(t)
+	defaultConfig(t)
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "jobs.csv"), filepath.Join(dir, "results.csv")
+	cache, err := solver.NewCache(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := scanConfig{
+		InFile: in, OutFile: out,
+		InFormat: FORMAT_CSV, OutFormat: FORMAT_CSV,
+		CSV:     jobfile.Options{Delimiter: ',', Defaults: jobfile.Defaults{Tol: 1e-9, MaxIter: 100, Algorithm: "auto"}},
+		Workers: 2, Checkpoint: 5, Cache: cache,
+	}
+
+	// waitFor polls the results until they hold the row tagged tag.
+	waitFor := func(tag string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			data, _ := os.ReadFile(out)
+			if strings.Contains(string(data), ","+tag+"\n") {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("results never had %s: %s", tag, data)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The watch waits for an input that does not exist yet
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watchScan(ctx, cfg, 5*time.Millisecond) }()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("results written without an input: %v", err)
+	}
+
+	writeTestJobs(t, in, 10, 100)
+	waitFor("row10")
+	first := cache.Stats()
+	if first.Misses == 0 || first.Hits != 0 {
+		t.Fatalf("first run: Stats() = %+v, want every job solved", first)
+	}
+
+	// The rows cached by the first run are not solved again
+	writeTestJobs(t, in, 15, 100)
+	waitFor("row15")
+	if stats := cache.Stats(); stats.Hits != int64(first.Entries) || stats.Misses == first.Misses {
+		t.Errorf("second run: Stats() = %+v, want the %d cached jobs unchanged and the added ones solved", stats, first.Entries)
+	}
+
+	// Runs are atomic, without checkpoints or temporary files left over
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 2 {
+		t.Errorf("files left by the watch: %v, %v", entries, err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watchScan() = %v, want nil once stopped", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchScan() did not stop")
+	}
+}
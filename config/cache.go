@@ -0,0 +1,17 @@
+package config
+
+import "github.com/AbdallahZerfaoui/poweq/solver"
+
+type Cache struct {
+	Enabled bool
+	Size    int    // Jobs kept in memory
+	Dir     string // Directory storing the results on disk, empty for memory only
+}
+
+// Open creates the cache, nil when it is disabled.
+func (c Cache) Open() (*solver.Cache, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	return solver.NewCache(c.Size, c.Dir)
+}
@@ -0,0 +1,406 @@
+// Package config loads the settings shared by the poweq CLI and API.
+// Each setting is layered: built-in default, then config file, then
+// POWEQ_ environment variables. Command line flags come last: commands
+// take the loaded values as the defaults of their flags.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Built-in defaults
+const (
+	DEFAULT_ALGORITHM  = "auto"
+	DEFAULT_TOL        = 1e-6
+	DEFAULT_MAX_ITER   = 100
+	DEFAULT_WORKERS    = 1
+	DEFAULT_CHECKPOINT = 1000
+	DEFAULT_REJECTS    = "rejects.csv"
+	DEFAULT_API_PORT   = 8080
+	DEFAULT_API_MODE   = "release"
+	DEFAULT_LOG_LEVEL  = "info"
+	DEFAULT_LOG_FORMAT = LOG_FORMAT_TEXT
+)
+
+// Limits of the API
+const (
+	DEFAULT_API_BODY    = 10 << 20 // Bytes
+	DEFAULT_API_BATCH   = 1000
+	DEFAULT_API_WORKERS = 4
+	DEFAULT_API_TASK    = 100000
+	DEFAULT_API_TASKS   = 1 // Tasks run at once
+
+	DEFAULT_API_TASK_TTL = 24 * time.Hour // Finished tasks kept
+)
+
+// Environment
+const (
+	ENV_PREFIX = "POWEQ_"
+	ENV_CONFIG = "POWEQ_CONFIG" // Path of the config file
+)
+
+// Files looked for in the working directory when no path is given
+var defaultFiles = []string{"poweq.yaml", "poweq.yml", "poweq.toml", "poweq.json"}
+
+// Sources of a setting
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_FILE    = "file"
+	SOURCE_ENV     = "env"
+	SOURCE_FLAG    = "flag"
+)
+
+type Config struct {
+	Solver Solver
+	Output Output
+	Scan   Scan
+	Cache  Cache
+	API    API
+	Log    Log
+}
+
+// Solver holds the defaults of jobs that leave them unset.
+type Solver struct {
+	Algorithm string
+	Tolerance float64
+	MaxIter   int
+	Precision int
+	Criterion string
+	Timeout   time.Duration
+}
+
+type Output struct {
+	Format string // Used when neither the flags nor the file extension set it
+	Digits int
+}
+
+type Scan struct {
+	Workers    int
+	Checkpoint int
+	Rejects    string
+}
+
+type API struct {
+	Host     string
+	Port     int
+	Mode     string // Gin mode: release, debug or test
+	MaxBody  int    // Largest request body in bytes, 0 for no limit
+	MaxBatch int    // Most jobs in a batch request
+	Workers  int    // Jobs of batch requests solved in parallel, shared by all requests
+
+	MaxTask     int           // Most jobs in a task of /jobs
+	TaskWorkers int           // Tasks run at once
+	StoreDir    string        // Directory keeping the tasks across restarts, empty for memory only
+	TaskTTL     time.Duration // How long finished tasks are kept, 0 until deleted
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		Solver: Solver{Algorithm: DEFAULT_ALGORITHM, Tolerance: DEFAULT_TOL, MaxIter: DEFAULT_MAX_ITER},
+		Scan:   Scan{Workers: DEFAULT_WORKERS, Checkpoint: DEFAULT_CHECKPOINT, Rejects: DEFAULT_REJECTS},
+		Cache:  Cache{Enabled: true, Size: solver.DEFAULT_CACHE_SIZE},
+		API:    API{Port: DEFAULT_API_PORT, Mode: DEFAULT_API_MODE, MaxBody: DEFAULT_API_BODY, MaxBatch: DEFAULT_API_BATCH, Workers: DEFAULT_API_WORKERS, MaxTask: DEFAULT_API_TASK, TaskWorkers: DEFAULT_API_TASKS, TaskTTL: DEFAULT_API_TASK_TTL},
+		Log:    Log{Level: DEFAULT_LOG_LEVEL, Format: DEFAULT_LOG_FORMAT},
+	}
+}
+
+// setting binds a key of the config file to a field of Config.
+type setting struct {
+	Key string // section.name
+	Doc string
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+func stringSetting(key, doc string, field func(c *Config) *string) setting {
+	return setting{key, doc,
+		func(c *Config) string { return *field(c) },
+		func(c *Config, value string) error { *field(c) = value; return nil }}
+}
+
+func intSetting(key, doc string, field func(c *Config) *int) setting {
+	return setting{key, doc,
+		func(c *Config) string { return strconv.Itoa(*field(c)) },
+		func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", value)
+			}
+			*field(c) = n
+			return nil
+		}}
+}
+
+func boolSetting(key, doc string, field func(c *Config) *bool) setting {
+	return setting{key, doc,
+		func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		func(c *Config, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", value)
+			}
+			*field(c) = b
+			return nil
+		}}
+}
+
+func floatSetting(key, doc string, field func(c *Config) *float64) setting {
+	return setting{key, doc,
+		func(c *Config) string { return strconv.FormatFloat(*field(c), 'g', -1, 64) },
+		func(c *Config, value string) error {
+			x, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", value)
+			}
+			*field(c) = x
+			return nil
+		}}
+}
+
+// durationSetting accepts a Go duration or a number of seconds.
+func durationSetting(key, doc string, field func(c *Config) *time.Duration) setting {
+	return setting{key, doc,
+		func(c *Config) string { return field(c).String() },
+		func(c *Config, value string) error {
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				*field(c) = time.Duration(seconds * float64(time.Second))
+				return nil
+			}
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", value)
+			}
+			*field(c) = d
+			return nil
+		}}
+}
+
+var settings = []setting{
+	stringSetting("solver.algorithm", "Default algorithm: newton, bisection or auto", func(c *Config) *string { return &c.Solver.Algorithm }),
+	floatSetting("solver.tolerance", "Default tolerance", func(c *Config) *float64 { return &c.Solver.Tolerance }),
+	intSetting("solver.max_iter", "Default maximum number of iterations", func(c *Config) *int { return &c.Solver.MaxIter }),
+	intSetting("solver.precision", "Default significant digits required on x, 0 to use the tolerance", func(c *Config) *int { return &c.Solver.Precision }),
+	stringSetting("solver.criterion", "Default stopping criterion: step, residual, both or any", func(c *Config) *string { return &c.Solver.Criterion }),
+	durationSetting("solver.timeout", "Default time limit per job, 0 for none", func(c *Config) *time.Duration { return &c.Solver.Timeout }),
+	stringSetting("output.format", "Output format when neither the flags nor the file extension set it", func(c *Config) *string { return &c.Output.Format }),
+	intSetting("output.digits", "Significant digits of the roots, 0 for the shortest exact form", func(c *Config) *int { return &c.Output.Digits }),
+	intSetting("scan.workers", "Number of jobs solved in parallel", func(c *Config) *int { return &c.Scan.Workers }),
+	intSetting("scan.checkpoint", "Rows between checkpoints, 0 to disable them", func(c *Config) *int { return &c.Scan.Checkpoint }),
+	stringSetting("scan.rejects", "File listing rejected rows, empty to disable it", func(c *Config) *string { return &c.Scan.Rejects }),
+	boolSetting("cache.enabled", "Reuse the results of jobs already solved with the same parameters", func(c *Config) *bool { return &c.Cache.Enabled }),
+	intSetting("cache.size", "Number of jobs whose results are kept in memory", func(c *Config) *int { return &c.Cache.Size }),
+	stringSetting("cache.dir", "Directory also storing the results on disk, empty for memory only", func(c *Config) *string { return &c.Cache.Dir }),
+	stringSetting("api.host", "Address the API listens on, empty for all", func(c *Config) *string { return &c.API.Host }),
+	intSetting("api.port", "Port of the API", func(c *Config) *int { return &c.API.Port }),
+	stringSetting("api.mode", "Gin mode of the API: release, debug or test", func(c *Config) *string { return &c.API.Mode }),
+	intSetting("api.max_body", "Largest request body of the API in bytes, 0 for no limit", func(c *Config) *int { return &c.API.MaxBody }),
+	intSetting("api.max_batch", "Most jobs in a batch request", func(c *Config) *int { return &c.API.MaxBatch }),
+	intSetting("api.workers", "Jobs of batch requests solved in parallel, shared by all requests", func(c *Config) *int { return &c.API.Workers }),
+	intSetting("api.max_task", "Most jobs in a task submitted to /jobs", func(c *Config) *int { return &c.API.MaxTask }),
+	intSetting("api.task_workers", "Tasks of /jobs run at once", func(c *Config) *int { return &c.API.TaskWorkers }),
+	stringSetting("api.store_dir", "Directory keeping the tasks of /jobs across restarts, empty for memory only", func(c *Config) *string { return &c.API.StoreDir }),
+	durationSetting("api.task_ttl", "How long finished tasks of /jobs are kept, 0 until deleted", func(c *Config) *time.Duration { return &c.API.TaskTTL }),
+	stringSetting("log.level", "Minimum level of the logs: trace, debug, info, warn or error", func(c *Config) *string { return &c.Log.Level }),
+	stringSetting("log.format", "Format of the logs: text or json", func(c *Config) *string { return &c.Log.Format }),
+}
+
+// Keys lists the settings, in the order of the sections.
+func Keys() []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+func lookup(key string) (setting, bool) {
+	i := slices.IndexFunc(settings, func(s setting) bool { return s.Key == key })
+	if i < 0 {
+		return setting{}, false
+	}
+	return settings[i], true
+}
+
+// Doc describes the setting key.
+func Doc(key string) string {
+	s, _ := lookup(key)
+	return s.Doc
+}
+
+// EnvName is the environment variable of a key: solver.max_iter is
+// POWEQ_SOLVER_MAX_ITER.
+func EnvName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Loaded is the effective configuration with where each setting came from.
+type Loaded struct {
+	Config
+	File    string            // Config file read, empty if none
+	Sources map[string]string // Source of each key, "file path" or "env NAME" for instance
+}
+
+// Value returns the effective value of key, as text.
+func (l *Loaded) Value(key string) string {
+	s, _ := lookup(key)
+	return s.get(&l.Config)
+}
+
+// Set overrides key, recording where the value came from.
+func (l *Loaded) Set(key, value, source string) error {
+	s, ok := lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err := s.set(&l.Config, value); err != nil {
+		return fmt.Errorf("%s (%s): %w", key, source, err)
+	}
+	l.Sources[key] = source
+	return nil
+}
+
+// Load layers the config file and the environment over the defaults.
+// The file is path if set, else $POWEQ_CONFIG, else the first of
+// poweq.yaml, poweq.yml, poweq.toml and poweq.json in the working directory.
+// Only an explicit file is required to exist.
+func Load(path string, environ []string) (*Loaded, error) {
+	l := &Loaded{Config: Default(), Sources: map[string]string{}}
+	for _, key := range Keys() {
+		l.Sources[key] = SOURCE_DEFAULT
+	}
+	env := map[string]string{}
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+
+	if path == "" {
+		path = env[ENV_CONFIG]
+	}
+	if path == "" {
+		for _, name := range defaultFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			if err := l.Set(key, values[key], SOURCE_FILE+" "+path); err != nil {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
+		}
+		l.File = path
+	}
+
+	for _, key := range Keys() {
+		name := EnvName(key)
+		if value, ok := env[name]; ok {
+			if err := l.Set(key, value, SOURCE_ENV+" "+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
+
+// readFile decodes a config file by its extension and flattens it to
+// section.name keys.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("config file %s not found", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tree := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var raw map[any]any
+		if err = yaml.Unmarshal(data, &raw); err == nil && raw != nil {
+			tree = normalize(raw).(map[string]any)
+		}
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q, expected .yaml, .yml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	// Keys and sections left empty are not set
+	for section, body := range tree {
+		if body == nil {
+			continue
+		}
+		fields, ok := body.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("config file %s: %q must be a section", path, section)
+		}
+		for name, value := range fields {
+			if value == nil {
+				continue
+			}
+			values[strings.ToLower(section+"."+name)] = scalar(value)
+		}
+	}
+	return values, nil
+}
+
+// normalize turns the map[any]any of YAML into map[string]any.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// scalar writes a decoded value back as text, numbers without exponent
+// so that integers stay integers.
+func scalar(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadLayers(t *testing.T) {
+	type want struct {
+		key, value, source string // source without the file path or variable name
+	}
+	tests := []struct {
+		name string
+		file string // name, then contents
+		data string
+		env  []string
+		want []want
+	}{
+		{
+			name: "defaults",
+			want: []want{
+				{"solver.max_iter", "100", SOURCE_DEFAULT},
+				{"scan.rejects", DEFAULT_REJECTS, SOURCE_DEFAULT},
+				{"cache.enabled", "true", SOURCE_DEFAULT},
+			},
+		},
+		{
+			name: "yaml over defaults",
+			file: "poweq.yaml",
+			data: "solver:\n  max_iter: 50\n  tolerance: 1e-9\nscan:\n  rejects: \"\"\n",
+			want: []want{
+				{"solver.max_iter", "50", SOURCE_FILE},
+				{"solver.tolerance", "1e-09", SOURCE_FILE},
+				{"scan.rejects", "", SOURCE_FILE},
+				{"scan.workers", "1", SOURCE_DEFAULT},
+			},
+		},
+		{
+			name: "env over file",
+			file: "poweq.toml",
+			data: "[solver]\nmax_iter = 50\n[scan]\nworkers = 2\n",
+			env:  []string{"POWEQ_SOLVER_MAX_ITER=70", "OTHER=1"},
+			want: []want{
+				{"solver.max_iter", "70", SOURCE_ENV},
+				{"scan.workers", "2", SOURCE_FILE},
+			},
+		},
+		{
+			name: "env over defaults",
+			env:  []string{"POWEQ_CACHE_ENABLED=false", "POWEQ_SOLVER_TIMEOUT=2s"},
+			want: []want{
+				{"cache.enabled", "false", SOURCE_ENV},
+				{"solver.timeout", "2s", SOURCE_ENV},
+			},
+		},
+		{
+			name: "json",
+			file: "poweq.json",
+			data: `{"api": {"port": 9000, "max_body": 1048576}}`,
+			want: []want{
+				{"api.port", "9000", SOURCE_FILE},
+				{"api.max_body", "1048576", SOURCE_FILE},
+			},
+		},
+		{
+			name: "empty yaml keys and sections",
+			file: "poweq.yml",
+			data: "solver:\n  max_iter:\nscan:\n",
+			want: []want{
+				{"solver.max_iter", "100", SOURCE_DEFAULT},
+				{"scan.workers", "1", SOURCE_DEFAULT},
+			},
+		},
+		{
+			name: "empty yaml file",
+			file: "poweq.yaml",
+			want: []want{{"solver.max_iter", "100", SOURCE_DEFAULT}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), tt.file)
+				if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			l, err := Load(path, tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.File != path {
+				t.Errorf("File = %q, want %q", l.File, path)
+			}
+			for _, w := range tt.want {
+				if value := l.Value(w.key); value != w.value {
+					t.Errorf("%s = %q, want %q", w.key, value, w.value)
+				}
+				if source, _, _ := strings.Cut(l.Sources[w.key], " "); source != w.source {
+					t.Errorf("source of %s = %q, want %q", w.key, l.Sources[w.key], w.source)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigPathFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.yaml")
+	if err := os.WriteFile(path, []byte("scan:\n  checkpoint: 10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Load("", []string{ENV_CONFIG + "=" + path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.File != path || l.Scan.Checkpoint != 10 {
+		t.Errorf("Load() read %q with checkpoint %d, want %q with 10", l.File, l.Scan.Checkpoint, path)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		env  []string
+		err  string
+	}{
+		{"missing file", "missing.yaml", "", nil, "not found"},
+		{"unknown extension", "poweq.ini", "[solver]\n", nil, "unsupported extension"},
+		{"unknown key", "poweq.yaml", "solver:\n  speed: 2\n", nil, `unknown setting "solver.speed"`},
+		{"not a section", "poweq.yaml", "solver: 2\n", nil, `"solver" must be a section`},
+		{"invalid value in file", "poweq.toml", "[scan]\nworkers = \"many\"\n", nil, "scan.workers"},
+		{"invalid value in env", "", "", []string{"POWEQ_SOLVER_TIMEOUT=soon"}, "POWEQ_SOLVER_TIMEOUT"},
+		{"invalid json", "poweq.json", "{", nil, "poweq.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), tt.file)
+				if tt.data != "" {
+					if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			_, err := Load(path, tt.env)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Load() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// LEVEL_TRACE is the name of the level logging every solver iteration
+const LEVEL_TRACE = "trace"
+
+// Log formats
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+// Names accepted by Log, for completion and help
+var (
+	LogLevels  = []string{LEVEL_TRACE, "debug", "info", "warn", "error"}
+	LogFormats = []string{LOG_FORMAT_TEXT, LOG_FORMAT_JSON}
+)
+
+type Log struct {
+	Level  string // trace, debug, info, warn or error
+	Format string // text or json
+}
+
+// Handler creates the slog handler writing the logs to w.
+func (l Log) Handler(w io.Writer) (slog.Handler, error) {
+	var level slog.Level
+	if strings.EqualFold(l.Level, LEVEL_TRACE) {
+		level = solver.LEVEL_TRACE
+	} else if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, expected trace, debug, info, warn or error", l.Level)
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: traceLevelName}
+	switch strings.ToLower(l.Format) {
+	case LOG_FORMAT_TEXT:
+		return slog.NewTextHandler(w, opts), nil
+	case LOG_FORMAT_JSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected %s or %s", l.Format, LOG_FORMAT_TEXT, LOG_FORMAT_JSON)
+	}
+}
+
+// traceLevelName writes the trace level as TRACE rather than DEBUG-4.
+func traceLevelName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == solver.LEVEL_TRACE {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
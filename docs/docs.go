@@ -1,4 +1,5 @@
-// Package docs Code generated by swaggo/swag. DO NOT EDIT
+// Code generated by swaggo/swag. DO NOT EDIT.
+
 package docs
 
 import "github.com/swaggo/swag"
@@ -18,7 +19,800 @@ const docTemplate = `{
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
-    "paths": {}
+    "paths": {
+        "/explain": {
+            "post": {
+                "description": "Analyses x^n = K * m^x without solving it: log form, x_limit, expected number of roots with the reasoning, initial guesses and intervals of the methods, and the Lambert W closed form",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "solver"
+                ],
+                "summary": "Explain a power equation",
+                "parameters": [
+                    {
+                        "description": "Solve Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/solver.SolveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/solver.Explanation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Returns the API status, with the hits and misses of the result cache when it is enabled",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "get": {
+                "description": "Lists the jobs submitted to POST /jobs, oldest first, with their status and progress but not their results",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List the submitted batches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only the jobs with this status: queued, running, done or canceled",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Task"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Queues an array of jobs to be solved in the background, for batches too large or too slow for one request. Poll GET /jobs/{id} for the progress and the results.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Submit a batch of power equations",
+                "parameters": [
+                    {
+                        "description": "Jobs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/solver.SolveRequest"
+                            }
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Significant digits of the roots, 0 or absent for the exact value",
+                        "name": "digits",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/main.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "description": "Returns the status and progress of a job submitted to POST /jobs, with its results once it is done or canceled",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get a submitted batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Task"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Cancels a queued or running job, keeping the results of the equations already solved. A job that is done or canceled is deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Cancel or delete a submitted batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Task"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/plot": {
+            "post": {
+                "description": "Draws f(x) = n ln(x) - ln(K) - x ln(m), or both sides of x^n = K * m^x, as SVG with the roots, x_limit and initial guesses marked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "solver"
+                ],
+                "summary": "Plot a power equation",
+                "parameters": [
+                    {
+                        "description": "Solve Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/solver.SolveRequest"
+                        }
+                    },
+                    {
+                        "type": "number",
+                        "description": "Left end of the plot, default a",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Right end of the plot, default b",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Plot ln(x^n) and ln(K m^x) instead of f",
+                        "name": "sides",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scale of x: auto, linear or log",
+                        "name": "scale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, default 800",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, default 480",
+                        "name": "height",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SVG image",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scan": {
+            "post": {
+                "description": "Solves the rows of a CSV file in the input format of poweq scan, sent as the \"file\" field of a form, and streams back the results as poweq scan writes them: CSV by default, a JSON array or NDJSON when the Accept header asks for it. Rows that cannot be read are rejected in the results without failing the others.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "text/csv",
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "solver"
+                ],
+                "summary": "Solve an uploaded jobs file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Jobs file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Significant digits of the roots, 0 or absent for the exact value",
+                        "name": "digits",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Field delimiter of the file and of the CSV results, default ',' ('tab' for TSV)",
+                        "name": "delim",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Lines starting with this character are ignored, default '#' (empty to disable)",
+                        "name": "comment",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail on unknown columns instead of copying them to the results",
+                        "name": "strict-columns",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Results",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/solve": {
+            "post": {
+                "description": "Solves x^n = K * m^x using the specified algorithm",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "solver"
+                ],
+                "summary": "Solve a power equation",
+                "parameters": [
+                    {
+                        "description": "Solve Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/solver.SolveRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Significant digits of the roots, 0 or absent for the exact value",
+                        "name": "digits",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/solver.SolveResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/solve/batch": {
+            "post": {
+                "description": "Solves an array of jobs concurrently, on a pool shared by all requests. Results come in the order of the jobs, each with the client id and an echo of the job. A job that is invalid gets an error in its result without failing the others.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "solver"
+                ],
+                "summary": "Solve a batch of power equations",
+                "parameters": [
+                    {
+                        "description": "Jobs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/solver.SolveRequest"
+                            }
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Significant digits of the roots, 0 or absent for the exact value",
+                        "name": "digits",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "main.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "description": "Jobs rejected, with Error set in their result",
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/solver.SolveResponse"
+                    }
+                }
+            }
+        },
+        "main.Task": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "string"
+                },
+                "digits": {
+                    "type": "integer"
+                },
+                "done": {
+                    "description": "Jobs completed so far",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Jobs rejected, once finished",
+                    "type": "integer"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "results": {
+                    "description": "In the order of the jobs, once finished",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/solver.SolveResponse"
+                    }
+                },
+                "started": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Jobs in the task",
+                    "type": "integer"
+                }
+            }
+        },
+        "solver.Explanation": {
+            "type": "object",
+            "properties": {
+                "analytic": {
+                    "description": "Closed-form root of an edge case, which skips the methods",
+                    "type": "number"
+                },
+                "derivative": {
+                    "description": "f'(x)",
+                    "type": "string"
+                },
+                "edge_case": {
+                    "type": "string"
+                },
+                "equation": {
+                    "description": "x^n = K m^x with the values of the job",
+                    "type": "string"
+                },
+                "exact_roots": {
+                    "description": "Closed-form roots in [A, B]",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "expected_roots": {
+                    "description": "In [A, B]",
+                    "type": "integer"
+                },
+                "f_x_limit": {
+                    "description": "f(x_limit), solutions exist when it is not below 0",
+                    "type": "number"
+                },
+                "function": {
+                    "description": "f(x), whose roots are the solutions",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "initial_guesses": {
+                    "description": "Starting points of Newton",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "intervals": {
+                    "description": "Brackets of bisection",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "lambert_w": {
+                    "$ref": "#/definitions/solver.LambertWForm"
+                },
+                "log_form": {
+                    "description": "n ln(x) = ln(K) + x ln(m)",
+                    "type": "string"
+                },
+                "reasoning": {
+                    "description": "How ExpectedRoots follows from the shape of f",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "solutions_exist": {
+                    "type": "boolean"
+                },
+                "x_limit": {
+                    "description": "Top of f, where f'(x) = 0",
+                    "type": "number"
+                }
+            }
+        },
+        "solver.LambertWForm": {
+            "type": "object",
+            "properties": {
+                "c": {
+                    "type": "number"
+                },
+                "root_w0": {
+                    "description": "Smaller root, -W0(z)/c, in [A, B] or not",
+                    "type": "number"
+                },
+                "root_w_minus_1": {
+                    "description": "Larger root, -W-1(z)/c",
+                    "type": "number"
+                },
+                "w0": {
+                    "type": "number"
+                },
+                "w_minus_1": {
+                    "type": "number"
+                },
+                "z": {
+                    "type": "number"
+                }
+            }
+        },
+        "solver.Solution": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "newton"
+                },
+                "steps": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "x": {
+                    "type": "number",
+                    "example": 2
+                }
+            }
+        },
+        "solver.SolveRequest": {
+            "type": "object",
+            "required": [
+                "a",
+                "b",
+                "k",
+                "m",
+                "n"
+            ],
+            "properties": {
+                "a": {
+                    "type": "number",
+                    "example": 0.1
+                },
+                "algorithm": {
+                    "type": "string",
+                    "example": "newton"
+                },
+                "b": {
+                    "type": "number",
+                    "example": 10
+                },
+                "criterion": {
+                    "type": "string",
+                    "example": "step"
+                },
+                "extra": {
+                    "description": "Passthrough data, echoed in the response",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "k": {
+                    "type": "number",
+                    "example": 1
+                },
+                "m": {
+                    "type": "number",
+                    "example": 2.718281828
+                },
+                "max_iter": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "n": {
+                    "type": "number",
+                    "example": 2
+                },
+                "precision": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "timeout": {
+                    "description": "Go duration",
+                    "type": "string",
+                    "example": "500ms"
+                },
+                "tolerance": {
+                    "type": "number",
+                    "example": 0.000001
+                }
+            }
+        },
+        "solver.SolveResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Set when the job itself was rejected",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job": {
+                    "description": "Echo of the job, when solved in a batch",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/solver.SolveRequest"
+                        }
+                    ]
+                },
+                "solutions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/solver.Solution"
+                    }
+                }
+            }
+        }
+    }
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
@@ -31,8 +825,6 @@ var SwaggerInfo = &swag.Spec{
 	Description:      "This is a REST API for solving power equations of the form x^n = K * m^x.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
-	LeftDelim:        "{{",
-	RightDelim:       "}}",
 }
 
 func init() {
@@ -0,0 +1,369 @@
+package jobfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/arrio"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Rows written per Arrow record batch or Parquet row group, and read per
+// batch from Parquet. Batches are also cut on Flush.
+const ARROW_BATCH_ROWS = 4096
+
+// Types of the Arrow result columns, strings for the others
+var (
+	arrowIntColumns   = []string{COL_ID, COL_MAX_ITER, COL_PRECISION, COL_STEPS}
+	arrowFloatColumns = []string{COL_N, COL_M, COL_K, COL_A, COL_B, COL_TOL, COL_X}
+)
+
+// arrowJobReader reads jobs out of the record batches of an Arrow IPC
+// or Parquet file. Columns are mapped by name as in a CSV header, and
+// cells turned to text so that they go through the same parsing.
+type arrowJobReader struct {
+	records arrio.Reader
+	schema  *csvSchema
+	batch   arrow.RecordBatch // Being read, released by the reader on the next one
+	cursor  int               // Next row in the batch
+	row     int               // Rows read so far, the line of a record
+	done    bool
+	err     error
+}
+
+// newArrowJobReader reads the schema of an Arrow IPC file and prepares
+// the column mapping.
+func newArrowJobReader(r io.Reader, opts Options) (*arrowJobReader, error) {
+	file, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := ipc.NewFileReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("not an Arrow IPC file: %w", err)
+	}
+	return newBatchJobReader(fr, fr.Schema(), opts)
+}
+
+// readSeeker returns r if it can seek, as Arrow and Parquet readers need
+// to since the file footer is at the end. Other inputs are read into
+// memory first.
+func readSeeker(r io.Reader) (ipc.ReadAtSeeker, error) {
+	if file, ok := r.(ipc.ReadAtSeeker); ok {
+		if _, err := file.Seek(0, io.SeekCurrent); err == nil {
+			return file, nil
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// newBatchJobReader checks that every column of schema can be read as
+// text and maps them to job fields.
+func newBatchJobReader(records arrio.Reader, schema *arrow.Schema, opts Options) (*arrowJobReader, error) {
+	header := make([]string, len(schema.Fields()))
+	for i, field := range schema.Fields() {
+		if !arrowTextType(field.Type) {
+			return nil, fmt.Errorf("invalid header: column %q has unsupported type %s", field.Name, field.Type)
+		}
+		header[i] = field.Name
+	}
+	mapping, err := newCSVSchema(header, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	return &arrowJobReader{records: records, schema: mapping}, nil
+}
+
+// arrowTextType tells whether the cells of a column of type dt can be
+// read as text.
+func arrowTextType(dt arrow.DataType) bool {
+	switch dt.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64,
+		arrow.FLOAT32, arrow.FLOAT64, arrow.STRING, arrow.LARGE_STRING, arrow.BINARY, arrow.BOOL, arrow.NULL:
+		return true
+	}
+	return false
+}
+
+// arrowCell formats the cell i of a column as text, null being empty.
+func arrowCell(column arrow.Array, i int) string {
+	if column.IsNull(i) {
+		return ""
+	}
+	switch a := column.(type) {
+	case *array.Int8:
+		return strconv.FormatInt(int64(a.Value(i)), 10)
+	case *array.Int16:
+		return strconv.FormatInt(int64(a.Value(i)), 10)
+	case *array.Int32:
+		return strconv.FormatInt(int64(a.Value(i)), 10)
+	case *array.Int64:
+		return strconv.FormatInt(a.Value(i), 10)
+	case *array.Uint8:
+		return strconv.FormatUint(uint64(a.Value(i)), 10)
+	case *array.Uint16:
+		return strconv.FormatUint(uint64(a.Value(i)), 10)
+	case *array.Uint32:
+		return strconv.FormatUint(uint64(a.Value(i)), 10)
+	case *array.Uint64:
+		return strconv.FormatUint(a.Value(i), 10)
+	case *array.Float32:
+		return strconv.FormatFloat(float64(a.Value(i)), 'g', -1, 32)
+	case *array.Float64:
+		return strconv.FormatFloat(a.Value(i), 'g', -1, 64)
+	case *array.String:
+		return a.Value(i)
+	case *array.LargeString:
+		return a.Value(i)
+	case *array.Binary:
+		return a.ValueString(i)
+	case *array.Boolean:
+		return strconv.FormatBool(a.Value(i))
+	}
+	return ""
+}
+
+// rows yields the cells of every row left, batch after batch.
+func (jr *arrowJobReader) rows() iter.Seq[[]string] {
+	return func(yield func([]string) bool) {
+		for !jr.done {
+			if jr.batch == nil || jr.cursor == int(jr.batch.NumRows()) {
+				batch, err := jr.records.Read()
+				if err != nil && err != io.EOF {
+					jr.err = err
+				}
+				if batch == nil {
+					jr.batch, jr.done = nil, true
+					return
+				}
+				jr.batch, jr.cursor = batch, 0
+				continue
+			}
+			cells := make([]string, jr.batch.NumCols())
+			for j, column := range jr.batch.Columns() {
+				cells[j] = arrowCell(column, jr.cursor)
+			}
+			jr.cursor++
+			jr.row++
+			if !yield(cells) {
+				return
+			}
+		}
+	}
+}
+
+// Records yields every row of the file with its passthrough data.
+// Rows that fail to parse are yielded too, with Err set.
+func (jr *arrowJobReader) Records() iter.Seq[Record] {
+	return func(yield func(Record) bool) {
+		for cells := range jr.rows() {
+			if !yield(jr.schema.record(cells, jr.row)) {
+				return
+			}
+		}
+	}
+}
+
+// Skip consumes the next n rows and returns the raw Id of the last one.
+func (jr *arrowJobReader) Skip(n int) (string, error) {
+	var lastId string
+	i := 0
+	if n > 0 {
+		for cells := range jr.rows() {
+			lastId = jr.schema.field(cells, COL_ID)
+			if i++; i == n {
+				break
+			}
+		}
+	}
+	if jr.err != nil {
+		return lastId, jr.err
+	}
+	if i < n {
+		return lastId, fmt.Errorf("input has only %d rows, expected at least %d", i, n)
+	}
+	return lastId, nil
+}
+
+// ExtraColumns returns the names of the passthrough columns.
+func (jr *arrowJobReader) ExtraColumns() []string {
+	return jr.schema.extraNames
+}
+
+func (jr *arrowJobReader) Err() error {
+	return jr.err
+}
+
+// arrowResultWriter writes results to an Arrow IPC or Parquet file,
+// with the columns of the CSV output, or jobs with the columns of a CSV
+// input. Numbers are typed, the other columns are strings, empty cells
+// being null.
+type arrowResultWriter struct {
+	out        io.Writer
+	open       func(w io.Writer, schema *arrow.Schema) (batchWriter, error)
+	writer     batchWriter // Opened with the first batch
+	builder    *array.RecordBuilder
+	extraNames []string
+	digits     int
+	rows       int // Rows in the builder
+}
+
+// batchWriter writes record batches to a file, and its footer on Close.
+type batchWriter interface {
+	Write(batch arrow.RecordBatch) error
+	Close() error
+}
+
+func newArrowResultWriter(w io.Writer, opts Options, extraNames []string) *arrowResultWriter {
+	return newBatchResultWriter(w, opts, resultFileColumns(), extraNames, openArrowFile)
+}
+
+func openArrowFile(w io.Writer, schema *arrow.Schema) (batchWriter, error) {
+	return ipc.NewFileWriter(w, ipc.WithSchema(schema))
+}
+
+// resultFileColumns lists the columns of a results file before the
+// passthrough ones.
+func resultFileColumns() []string {
+	return slices.Concat(RequiredColumns, OptionalColumns, resultColumns)
+}
+
+// newBatchResultWriter creates a writer of columns then extraNames, whose
+// batches go to the file writer made by open.
+func newBatchResultWriter(w io.Writer, opts Options, columns, extraNames []string,
+	open func(w io.Writer, schema *arrow.Schema) (batchWriter, error)) *arrowResultWriter {
+	names := slices.Concat(columns, extraNames)
+	fields := make([]arrow.Field, len(names))
+	for i, name := range names {
+		fields[i] = arrow.Field{Name: name, Type: arrow.BinaryTypes.String, Nullable: true}
+		if i >= len(names)-len(extraNames) {
+			// Passthrough columns may shadow result column names
+			continue
+		}
+		if slices.Contains(arrowIntColumns, name) {
+			fields[i].Type = arrow.PrimitiveTypes.Int64
+		} else if slices.Contains(arrowFloatColumns, name) {
+			fields[i].Type = arrow.PrimitiveTypes.Float64
+		}
+	}
+	return &arrowResultWriter{
+		out:        w,
+		open:       open,
+		builder:    array.NewRecordBuilder(memory.NewGoAllocator(), arrow.NewSchema(fields, nil)),
+		extraNames: extraNames,
+		digits:     opts.Digits,
+	}
+}
+
+// start opens the file on the output.
+func (aw *arrowResultWriter) start() error {
+	if aw.writer != nil {
+		return nil
+	}
+	var err error
+	aw.writer, err = aw.open(aw.out, aw.builder.Schema())
+	return err
+}
+
+// WriteHeader does nothing, the schema is written with the first batch.
+func (aw *arrowResultWriter) WriteHeader() error {
+	return nil
+}
+
+// appendRow appends the cells of a row, converting numeric columns.
+// Cells that are empty or not numbers are null.
+func (aw *arrowResultWriter) appendRow(cells []string) error {
+	for i, cell := range cells {
+		switch b := aw.builder.Field(i).(type) {
+		case *array.Int64Builder:
+			if v, err := strconv.ParseInt(cell, 10, 64); err == nil {
+				b.Append(v)
+			} else {
+				b.AppendNull()
+			}
+		case *array.Float64Builder:
+			if v, err := strconv.ParseFloat(cell, 64); err == nil {
+				b.Append(v)
+			} else {
+				b.AppendNull()
+			}
+		case *array.StringBuilder:
+			if cell != "" {
+				b.Append(cell)
+			} else {
+				b.AppendNull()
+			}
+		}
+	}
+	if aw.rows++; aw.rows >= ARROW_BATCH_ROWS {
+		return aw.Flush()
+	}
+	return nil
+}
+
+// Write adds the results of a job to the current batch, echoing the
+// job parameters and the passthrough columns on each row.
+func (aw *arrowResultWriter) Write(rec Record, results []solver.Result) error {
+	for _, result := range results {
+		cells := resultCells(result, aw.digits)
+		if result.Err == nil {
+			cells[len(cells)-1] = ""
+		}
+		row := slices.Concat(JobCells(rec), cells, fitExtra(rec, aw.extraNames))
+		if err := aw.appendRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRejected adds a placeholder row for a rejected record.
+func (aw *arrowResultWriter) WriteRejected(rec Record) error {
+	return aw.appendRow(append(rejectedCells(rec, aw.digits), fitExtra(rec, aw.extraNames)...))
+}
+
+// WriteJob adds the parameters and passthrough values of a job to the
+// current batch, in a file of jobs.
+func (aw *arrowResultWriter) WriteJob(rec Record) error {
+	return aw.appendRow(append(JobCells(rec), fitExtra(rec, aw.extraNames)...))
+}
+
+// Flush writes the rows added so far as a record batch.
+func (aw *arrowResultWriter) Flush() error {
+	if aw.rows == 0 {
+		return nil
+	}
+	if err := aw.start(); err != nil {
+		return err
+	}
+	batch := aw.builder.NewRecordBatch()
+	defer batch.Release()
+	aw.rows = 0
+	return aw.writer.Write(batch)
+}
+
+// Close writes the last batch and the file footer.
+func (aw *arrowResultWriter) Close() error {
+	defer aw.builder.Release()
+	if err := aw.Flush(); err != nil {
+		return err
+	}
+	if err := aw.start(); err != nil {
+		return err
+	}
+	return aw.writer.Close()
+}
@@ -0,0 +1,145 @@
+package jobfile
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+func TestArrowRoundTrip(t *testing.T) {
+	for _, format := range []string{FORMAT_ARROW, FORMAT_PARQUET} {
+		t.Run(format, func(t *testing.T) {
+			input := "Id,N,M,K,A,B,Tol,tag\n" +
+				"1,2,2,1,0.5,10,1e-9,first\n" +
+				"x,2,2,1,0.5,10,,bad\n" +
+				"3,0.5,1.5,2,1,100,,\n"
+			reader, err := NewReader(strings.NewReader(input), FORMAT_CSV, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			writer := NewWriter(&buf, format, Options{}, reader.ExtraColumns(), 0)
+			if err := writer.WriteHeader(); err != nil {
+				t.Fatal(err)
+			}
+			for rec := range reader.Records() {
+				if rec.Err != nil {
+					err = writer.WriteRejected(rec)
+				} else {
+					err = writer.Write(rec, []solver.Result{{X: 1.25, Steps: 4, Method: "newton"}})
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := NewReader(bytes.NewReader(buf.Bytes()), format, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantExtra := []string{COL_X, COL_STEPS, COL_METHOD, COL_ERROR, "tag"}
+			if extra := results.ExtraColumns(); !slices.Equal(extra, wantExtra) {
+				t.Fatalf("ExtraColumns() = %q, want %q", extra, wantExtra)
+			}
+
+			tests := []struct {
+				id    int
+				err   bool
+				extra []string
+			}{
+				{1, false, []string{"1.25", "4", "newton", "", "first"}},
+				// Id is an integer column, the raw id of a rejected row is lost
+				{0, true, []string{"-1", "0", "", "rejected", "bad"}},
+				{3, false, []string{"1.25", "4", "newton", "", ""}},
+			}
+			var got []Record
+			for rec := range results.Records() {
+				got = append(got, rec)
+			}
+			if err := results.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tests) {
+				t.Fatalf("read %d records, want %d", len(got), len(tests))
+			}
+			for i, tt := range tests {
+				rec := got[i]
+				if (rec.Err != nil) != tt.err {
+					t.Errorf("row %d: Err = %v, want error %v", i+1, rec.Err, tt.err)
+				}
+				if rec.Line != i+1 {
+					t.Errorf("row %d: Line = %d", i+1, rec.Line)
+				}
+				if !tt.err && rec.Job.Id != tt.id {
+					t.Errorf("row %d: Id = %d, want %d", i+1, rec.Job.Id, tt.id)
+				}
+				extra := slices.Clone(rec.Extra)
+				if len(extra) == len(tt.extra) && strings.HasPrefix(extra[3], "rejected") {
+					extra[3] = "rejected"
+				}
+				if !slices.Equal(extra, tt.extra) {
+					t.Errorf("row %d: Extra = %q, want %q", i+1, rec.Extra, tt.extra)
+				}
+			}
+			if got[0].Job.Tol != 1e-9 || got[2].Job.N != 0.5 {
+				t.Errorf("parameters not kept: %+v, %+v", got[0].Job, got[2].Job)
+			}
+		})
+	}
+}
+
+func TestArrowSkip(t *testing.T) {
+	for _, format := range []string{FORMAT_ARROW, FORMAT_PARQUET} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := NewWriter(&buf, format, Options{}, nil, 0)
+			for id := 1; id <= 5; id++ {
+				rec := Record{Job: solver.Job{Id: id, N: 2, M: 2, K: 1, A: 0.5, B: 10}}
+				if err := writer.Write(rec, []solver.Result{{X: 1}}); err != nil {
+					t.Fatal(err)
+				}
+				// Several record batches
+				if err := writer.Flush(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := NewReader(bytes.NewReader(buf.Bytes()), format, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			lastId, err := reader.Skip(3)
+			if err != nil || lastId != "3" {
+				t.Fatalf("Skip(3) = %q, %v, want \"3\"", lastId, err)
+			}
+			var ids []int
+			for rec := range reader.Records() {
+				ids = append(ids, rec.Job.Id)
+			}
+			if !slices.Equal(ids, []int{4, 5}) {
+				t.Errorf("ids after Skip = %v, want [4 5]", ids)
+			}
+			if _, err := reader.Skip(1); err == nil {
+				t.Error("Skip past the end: no error")
+			}
+		})
+	}
+}
+
+func TestArrowUnsupportedInput(t *testing.T) {
+	for _, format := range []string{FORMAT_ARROW, FORMAT_PARQUET} {
+		_, err := NewReader(strings.NewReader("Id,N,M,K,A,B\n"), format, Options{})
+		if err == nil {
+			t.Errorf("CSV read as %s: no error", format)
+		}
+	}
+}
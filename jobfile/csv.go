@@ -0,0 +1,556 @@
+package jobfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Columns understood by the jobs reader, in the order they are written back
+const (
+	COL_ID        = "Id"
+	COL_N         = "N"
+	COL_M         = "M"
+	COL_K         = "K"
+	COL_A         = "A"
+	COL_B         = "B"
+	COL_TOL       = "Tol"
+	COL_MAX_ITER  = "MaxIter"
+	COL_ALGORITHM = "Algorithm"
+	COL_PRECISION = "Precision"
+	COL_CRITERION = "Criterion"
+	COL_TIMEOUT   = "Timeout"
+)
+
+// Result columns, written after the job columns
+const (
+	COL_X      = "X"
+	COL_STEPS  = "Steps"
+	COL_METHOD = "Method" // Algorithm that actually produced the root
+	COL_ERROR  = "Error"
+)
+
+var (
+	RequiredColumns = []string{COL_ID, COL_N, COL_M, COL_K, COL_A, COL_B}
+	OptionalColumns = []string{COL_TOL, COL_MAX_ITER, COL_ALGORITHM, COL_PRECISION, COL_CRITERION, COL_TIMEOUT}
+	resultColumns   = []string{COL_X, COL_STEPS, COL_METHOD, COL_ERROR}
+
+	// Alternative spellings accepted in headers, keys are lower case
+	columnAliases = map[string]string{
+		"tolerance": COL_TOL,
+		"max_iter":  COL_MAX_ITER,
+		"maxiter":   COL_MAX_ITER,
+		"alg":       COL_ALGORITHM,
+		"digits":    COL_PRECISION,
+		"stop":      COL_CRITERION,
+	}
+)
+
+// Defaults fills the optional columns that are absent or left empty.
+type Defaults struct {
+	Tol       float64
+	MaxIter   int
+	Algorithm string
+	Precision int
+	Criterion string
+	Timeout   time.Duration
+}
+
+// Options controls how jobs files are parsed.
+type Options struct {
+	Delimiter     rune
+	Comment       rune // 0 disables comment lines
+	StrictColumns bool // Fail on columns that are not part of the schema
+	Defaults      Defaults
+	Digits        int // Significant digits of the roots, 0 for the exact value
+}
+
+// formatTimeout leaves the cell empty when there is no timeout.
+func formatTimeout(timeout time.Duration) string {
+	if timeout == 0 {
+		return ""
+	}
+	return timeout.String()
+}
+
+// ParseTimeout accepts a Go duration ("250ms", "2s") or a number of seconds.
+func ParseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ParseRune turns a flag value into a single delimiter character.
+// "tab" and "\t" are accepted for tab separated files, "" means none.
+func ParseRune(name, value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError {
+		return 0, fmt.Errorf("%s must be a single character, got %q", name, value)
+	}
+	return r, nil
+}
+
+// csvSchema maps the columns of a jobs file to job fields.
+type csvSchema struct {
+	width      int            // Number of fields in the header
+	index      map[string]int // canonical column name -> record position
+	defaults   Defaults
+	extra      []int // positions of passthrough columns
+	extraNames []string
+}
+
+func canonicalColumn(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, column := range slices.Concat(RequiredColumns, OptionalColumns) {
+		if strings.ToLower(column) == lower {
+			return column, true
+		}
+	}
+	column, ok := columnAliases[lower]
+	return column, ok
+}
+
+// newCSVSchema builds the schema from a header record.
+// Column names are matched case-insensitively and in any order.
+func newCSVSchema(header []string, opts Options) (*csvSchema, error) {
+	schema := &csvSchema{width: len(header), index: make(map[string]int), defaults: opts.Defaults}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if name == "" {
+			return nil, fmt.Errorf("column %d has an empty name", i+1)
+		}
+		column, known := canonicalColumn(name)
+		if !known {
+			if opts.StrictColumns {
+				return nil, fmt.Errorf("unknown column %q", name)
+			}
+			schema.extra = append(schema.extra, i)
+			schema.extraNames = append(schema.extraNames, name)
+			continue
+		}
+		if _, dup := schema.index[column]; dup {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		schema.index[column] = i
+	}
+	for _, column := range RequiredColumns {
+		if _, ok := schema.index[column]; !ok {
+			return nil, fmt.Errorf("missing required column %q", column)
+		}
+	}
+	return schema, nil
+}
+
+// field returns the trimmed value of a column, "" if absent from the file.
+func (s *csvSchema) field(record []string, column string) string {
+	i, ok := s.index[column]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func (s *csvSchema) float(record []string, column string, def float64) (float64, error) {
+	value := s.field(record, column)
+	if value == "" {
+		if isRequired(column) {
+			return 0, fmt.Errorf("column %q: missing value", column)
+		}
+		return def, nil
+	}
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("column %q: invalid number %q", column, value)
+	}
+	return x, nil
+}
+
+func (s *csvSchema) int(record []string, column string, def int) (int, error) {
+	value := s.field(record, column)
+	if value == "" {
+		if isRequired(column) {
+			return 0, fmt.Errorf("column %q: missing value", column)
+		}
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("column %q: invalid integer %q", column, value)
+	}
+	return n, nil
+}
+
+func isRequired(column string) bool {
+	return slices.Contains(RequiredColumns, column)
+}
+
+// Record is a job as read from a file, with the data that travels with it.
+// Rows that could not be turned into a job carry the reason in Err.
+type Record struct {
+	Job        solver.Job
+	Algorithm  string
+	Extra      []string // Passthrough values, copied to the output
+	ExtraNames []string // Names of the passthrough values
+	Line       int
+	Raw        []string // Fields as read, nil when the line could not be split
+	RawId      string   // Id as read, kept even when the row is rejected
+	Echo       []string // Values of the job columns as read, echoed in the output
+	Err        error
+	Elapsed    time.Duration // Time spent solving, set by SolveOrdered
+}
+
+// parse converts a record into a job, applying defaults to optional columns.
+func (s *csvSchema) parse(record []string) (Record, error) {
+	var rec Record
+	var err error
+	job := &rec.Job
+	def := s.defaults
+
+	if len(record) != s.width {
+		return rec, fmt.Errorf("expected %d fields, got %d", s.width, len(record))
+	}
+	if job.Id, err = s.int(record, COL_ID, 0); err != nil {
+		return rec, err
+	}
+	for _, f := range []struct {
+		column string
+		dst    *float64
+		def    float64
+	}{
+		{COL_N, &job.N, 0},
+		{COL_M, &job.M, 0},
+		{COL_K, &job.K, 0},
+		{COL_A, &job.A, 0},
+		{COL_B, &job.B, 0},
+		{COL_TOL, &job.Tol, def.Tol},
+	} {
+		if *f.dst, err = s.float(record, f.column, f.def); err != nil {
+			return rec, err
+		}
+	}
+	if job.MaxIter, err = s.int(record, COL_MAX_ITER, def.MaxIter); err != nil {
+		return rec, err
+	}
+	if job.Precision, err = s.int(record, COL_PRECISION, def.Precision); err != nil {
+		return rec, err
+	}
+
+	rec.Algorithm = strings.ToLower(s.field(record, COL_ALGORITHM))
+	if rec.Algorithm == "" {
+		rec.Algorithm = def.Algorithm
+	} else if !solver.IsMethod(rec.Algorithm) {
+		return rec, fmt.Errorf("column %q: unknown algorithm %q", COL_ALGORITHM, rec.Algorithm)
+	}
+
+	job.Criterion = strings.ToLower(s.field(record, COL_CRITERION))
+	if job.Criterion == "" {
+		job.Criterion = def.Criterion
+	} else if !solver.IsCriterion(job.Criterion) {
+		return rec, fmt.Errorf("column %q: unknown stopping criterion %q", COL_CRITERION, job.Criterion)
+	}
+
+	job.Timeout = def.Timeout
+	if value := s.field(record, COL_TIMEOUT); value != "" {
+		if job.Timeout, err = ParseTimeout(value); err != nil {
+			return rec, fmt.Errorf("column %q: invalid duration %q", COL_TIMEOUT, value)
+		}
+	}
+
+	rec.Extra = s.extraFields(record)
+	return rec, nil
+}
+
+// jobFields picks the values of the job columns out of a record, in
+// output order, leaving absent columns empty.
+func (s *csvSchema) jobFields(record []string) []string {
+	var fields []string
+	for _, column := range slices.Concat(RequiredColumns, OptionalColumns) {
+		fields = append(fields, s.field(record, column))
+	}
+	return fields
+}
+
+// JobCells formats the job columns of rec in output order. Cells are
+// echoed as read when there are any, so that inputs round-trip exactly;
+// values that come from defaults or from another format are written in
+// their shortest exact form.
+func JobCells(rec Record) []string {
+	job := rec.Job
+	cells := []string{
+		strconv.Itoa(job.Id),
+		solver.FormatFloat(job.N, solver.DIGITS_EXACT),
+		solver.FormatFloat(job.M, solver.DIGITS_EXACT),
+		solver.FormatFloat(job.K, solver.DIGITS_EXACT),
+		solver.FormatFloat(job.A, solver.DIGITS_EXACT),
+		solver.FormatFloat(job.B, solver.DIGITS_EXACT),
+		solver.FormatFloat(job.Tol, solver.DIGITS_EXACT),
+		strconv.Itoa(job.MaxIter),
+		rec.Algorithm,
+		strconv.Itoa(job.Precision),
+		job.Criterion,
+		formatTimeout(job.Timeout),
+	}
+	for i, cell := range rec.Echo {
+		if i < len(cells) && cell != "" {
+			cells[i] = cell
+		}
+	}
+	return cells
+}
+
+// extraFields picks the passthrough values out of a record.
+// Missing trailing fields are left empty.
+func (s *csvSchema) extraFields(record []string) []string {
+	extra := make([]string, len(s.extra))
+	for i, pos := range s.extra {
+		if pos < len(record) {
+			extra[i] = record[pos]
+		}
+	}
+	return extra
+}
+
+// record turns the fields of a row into a record with its passthrough
+// data. A row that fails to parse carries the reason in Err.
+func (s *csvSchema) record(fields []string, line int) Record {
+	rec, err := s.parse(fields)
+	rec.Line, rec.Raw, rec.Err = line, fields, err
+	rec.RawId = s.field(fields, COL_ID)
+	rec.ExtraNames = s.extraNames
+	rec.Echo = s.jobFields(fields)
+	if rec.Err != nil {
+		rec.Extra = s.extraFields(fields)
+	}
+	return rec
+}
+
+// csvJobReader streams jobs out of a CSV file one record at a time.
+// Like bufio.Scanner, a fatal read error stops the iteration and is
+// reported by Err once the loop is over.
+type csvJobReader struct {
+	reader *csv.Reader
+	schema *csvSchema
+	err    error
+}
+
+// newCSVJobReader reads the header of r and prepares the column mapping.
+// A header that does not fit the schema is reported immediately.
+func newCSVJobReader(r io.Reader, opts Options) (*csvJobReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Record length is checked per row below
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.Comment = opts.Comment
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("input file is empty, expected a header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema, err := newCSVSchema(header, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	return &csvJobReader{reader: reader, schema: schema}, nil
+}
+
+// Records yields every row of the file with its passthrough data.
+// Rows that are malformed or fail to parse are yielded too, with Err set,
+// so that the caller decides whether to reject them or to abort.
+func (jr *csvJobReader) Records() iter.Seq[Record] {
+	return func(yield func(Record) bool) {
+		for {
+			record, err := jr.reader.Read()
+			if err == io.EOF {
+				return
+			}
+			var rec Record
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rec = jr.schema.record(record, parseErr.StartLine)
+				rec.Err = parseErr.Err
+			} else if err != nil {
+				jr.err = err
+				return
+			} else {
+				line, _ := jr.reader.FieldPos(0)
+				rec = jr.schema.record(record, line)
+			}
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+// Skip consumes the next n rows without parsing them and returns the
+// raw Id of the last one, so that a resumed run can check it is looking
+// at the same file.
+func (jr *csvJobReader) Skip(n int) (string, error) {
+	var lastId string
+	for i := 0; i < n; i++ {
+		record, err := jr.reader.Read()
+		if err == io.EOF {
+			return lastId, fmt.Errorf("input has only %d rows, expected at least %d", i, n)
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return lastId, err
+		}
+		lastId = jr.schema.field(record, COL_ID)
+	}
+	return lastId, nil
+}
+
+// ExtraColumns returns the names of the passthrough columns.
+func (jr *csvJobReader) ExtraColumns() []string {
+	return jr.schema.extraNames
+}
+
+func (jr *csvJobReader) Err() error {
+	return jr.err
+}
+
+// csvResultWriter writes one CSV row per result as soon as it is produced.
+type csvResultWriter struct {
+	writer     *csv.Writer
+	extraNames []string
+	digits     int
+}
+
+func newCSVResultWriter(w io.Writer, opts Options, extraNames []string) *csvResultWriter {
+	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
+	return &csvResultWriter{writer: writer, extraNames: extraNames, digits: opts.Digits}
+}
+
+// WriteHeader writes the result columns followed by the passthrough
+// columns of the input schema.
+func (rw *csvResultWriter) WriteHeader() error {
+	header := slices.Concat(RequiredColumns, OptionalColumns, resultColumns, rw.extraNames)
+	return rw.writer.Write(header)
+}
+
+// Write writes the results of a job, echoing the job parameters and the
+// passthrough columns on each row.
+func (rw *csvResultWriter) Write(rec Record, results []solver.Result) error {
+	for _, result := range results {
+		row := append(JobCells(rec), resultCells(result, rw.digits)...)
+		if err := rw.writer.Write(append(row, fitExtra(rec, rw.extraNames)...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultCells formats the result columns of a row.
+func resultCells(result solver.Result, digits int) []string {
+	return []string{
+		solver.FormatFloat(result.X, digits),
+		strconv.Itoa(result.Steps),
+		result.Method,
+		fmt.Sprintf("%v", result.Err),
+	}
+}
+
+// fitExtra fits the passthrough values of rec to the columns names. Values
+// of a CSV input are in the order of the header; those of a JSON input
+// are matched by name, the header having the extra fields of its first job.
+func fitExtra(rec Record, names []string) []string {
+	extra := make([]string, len(names))
+	if slices.Equal(rec.ExtraNames, names) {
+		copy(extra, rec.Extra)
+		return extra
+	}
+	for i, name := range names {
+		extra[i], _ = rec.ExtraValue(name)
+	}
+	return extra
+}
+
+// WriteRejected writes a placeholder row for a rejected record, so that
+// every input row has a counterpart in the output.
+func (rw *csvResultWriter) WriteRejected(rec Record) error {
+	row := rejectedCells(rec, rw.digits)
+	return rw.writer.Write(append(row, fitExtra(rec, rw.extraNames)...))
+}
+
+// rejectedCells formats the job and result columns of a rejected record.
+// Parameters are echoed as read since they could not be parsed.
+func rejectedCells(rec Record, digits int) []string {
+	row := make([]string, len(RequiredColumns)+len(OptionalColumns))
+	if rec.Echo == nil {
+		// Not read from a table, only the id is known
+		row[0] = rec.RawId
+	}
+	copy(row, rec.Echo)
+	return append(row,
+		solver.FormatFloat(solver.DEFAULT_ERROR_SOLUTION, digits),
+		"0",
+		"",
+		fmt.Sprintf("rejected (line %d): %v", rec.Line, rec.Err),
+	)
+}
+
+// Flush pushes buffered rows to the underlying writer.
+func (rw *csvResultWriter) Flush() error {
+	rw.writer.Flush()
+	return rw.writer.Error()
+}
+
+func (rw *csvResultWriter) Close() error {
+	return rw.Flush()
+}
+
+// RejectWriter reports the rows that could not be turned into jobs.
+type RejectWriter struct {
+	writer    *csv.Writer
+	delimiter rune
+}
+
+func NewRejectWriter(w io.Writer, opts Options) *RejectWriter {
+	rw := &RejectWriter{writer: csv.NewWriter(w), delimiter: ','}
+	if opts.Delimiter != 0 {
+		rw.delimiter = opts.Delimiter
+	}
+	return rw
+}
+
+func (rw *RejectWriter) WriteHeader() error {
+	return rw.writer.Write([]string{"Line", "Record", "Reason"})
+}
+
+// Write records a rejected row, the raw fields joined with the input delimiter.
+func (rw *RejectWriter) Write(rec Record) error {
+	return rw.writer.Write([]string{
+		fmt.Sprintf("%d", rec.Line),
+		strings.Join(rec.Raw, string(rw.delimiter)),
+		rec.Err.Error(),
+	})
+}
+
+func (rw *RejectWriter) Flush() error {
+	rw.writer.Flush()
+	return rw.writer.Error()
+}
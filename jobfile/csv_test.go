@@ -0,0 +1,176 @@
+package jobfile
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+func TestCSVHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		strict bool
+		extra  []string
+		err    string
+	}{
+		{"required only", "Id,N,M,K,A,B", false, nil, ""},
+		{"any order and case", "b,a,k,m,n,id", false, nil, ""},
+		{"optional and aliases", "Id,N,M,K,A,B,tolerance,max_iter,alg,digits,stop,Timeout", false, nil, ""},
+		{"byte order mark", "\ufeffId,N,M,K,A,B", false, nil, ""},
+		{"extra columns", "tag,Id,N,M,K,A,B,note", false, []string{"tag", "note"}, ""},
+		{"strict columns", "Id,N,M,K,A,B,tag", true, nil, `unknown column "tag"`},
+		{"missing column", "Id,N,M,K,A", false, nil, `missing required column "B"`},
+		{"duplicate column", "Id,N,M,K,A,B,Tol,tolerance", false, nil, `duplicate column "tolerance"`},
+		{"empty name", "Id,N,M,K,A,B,", false, nil, "column 7 has an empty name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(tt.header+"\n"), FORMAT_CSV, Options{StrictColumns: tt.strict})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("NewReader() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if extra := reader.ExtraColumns(); !slices.Equal(extra, tt.extra) {
+				t.Errorf("ExtraColumns() = %q, want %q", extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestCSVRecords(t *testing.T) {
+	opts := Options{Defaults: Defaults{Tol: 1e-6, MaxIter: 100, Algorithm: "auto", Criterion: "step"}}
+	tests := []struct {
+		name  string
+		row   string
+		job   solver.Job
+		alg   string
+		extra []string
+		err   string
+	}{
+		{
+			name:  "defaults",
+			row:   "1,2,2,1,0.5,10,,,,,,,a",
+			job:   solver.Job{Id: 1, N: 2, M: 2, K: 1, A: 0.5, B: 10, Tol: 1e-6, MaxIter: 100, Criterion: "step"},
+			alg:   "auto",
+			extra: []string{"a"},
+		},
+		{
+			name:  "optional columns set",
+			row:   " 2 ,3,1.5,2,1,100,1e-9,50,Newton,6,Residual,250ms,b",
+			job:   solver.Job{Id: 2, N: 3, M: 1.5, K: 2, A: 1, B: 100, Tol: 1e-9, MaxIter: 50, Precision: 6, Criterion: "residual", Timeout: 250 * time.Millisecond},
+			alg:   "newton",
+			extra: []string{"b"},
+		},
+		{
+			name:  "timeout in seconds",
+			row:   "3,2,2,1,0.5,10,,,,,,1.5,",
+			job:   solver.Job{Id: 3, N: 2, M: 2, K: 1, A: 0.5, B: 10, Tol: 1e-6, MaxIter: 100, Criterion: "step", Timeout: 1500 * time.Millisecond},
+			alg:   "auto",
+			extra: []string{""},
+		},
+		{name: "missing value", row: "4,2,,1,0.5,10,,,,,,,", err: `column "M": missing value`},
+		{name: "invalid number", row: "5,2,x,1,0.5,10,,,,,,,", err: `column "M": invalid number "x"`},
+		{name: "invalid integer", row: "6,2,2,1,0.5,10,,1.5,,,,,", err: `column "MaxIter": invalid integer "1.5"`},
+		{name: "unknown algorithm", row: "7,2,2,1,0.5,10,,,secant,,,,", err: `unknown algorithm "secant"`},
+		{name: "unknown criterion", row: "8,2,2,1,0.5,10,,,,,never,,", err: `unknown stopping criterion "never"`},
+		{name: "invalid timeout", row: "9,2,2,1,0.5,10,,,,,,soon,", err: `column "Timeout": invalid duration "soon"`},
+	}
+	header := "Id,N,M,K,A,B,Tol,MaxIter,Algorithm,Precision,Criterion,Timeout,tag\n"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(header+tt.row+"\n"), FORMAT_CSV, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var recs []Record
+			for rec := range reader.Records() {
+				recs = append(recs, rec)
+			}
+			if err := reader.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) != 1 {
+				t.Fatalf("read %d records, want 1", len(recs))
+			}
+			rec := recs[0]
+			if rec.Line != 2 {
+				t.Errorf("Line = %d, want 2", rec.Line)
+			}
+			if tt.err != "" {
+				if rec.Err == nil || !strings.Contains(rec.Err.Error(), tt.err) {
+					t.Fatalf("Err = %v, want %q", rec.Err, tt.err)
+				}
+				return
+			}
+			if rec.Err != nil {
+				t.Fatal(rec.Err)
+			}
+			if rec.Job != tt.job {
+				t.Errorf("Job = %+v, want %+v", rec.Job, tt.job)
+			}
+			if rec.Algorithm != tt.alg {
+				t.Errorf("Algorithm = %q, want %q", rec.Algorithm, tt.alg)
+			}
+			if !slices.Equal(rec.Extra, tt.extra) {
+				t.Errorf("Extra = %q, want %q", rec.Extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestCSVResultColumns(t *testing.T) {
+	input := "tag,Id,N,M,K,A,B,Tol\n" +
+		"first,1,2,2,1,0.5,10,1e-09\n" +
+		"# comment\n" +
+		"bad,2,x,2,1,0.5,10,\n"
+	reader, err := NewReader(strings.NewReader(input), FORMAT_CSV, Options{Comment: '#'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writer := NewWriter(&buf, FORMAT_CSV, Options{}, reader.ExtraColumns(), 0)
+	if err := writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for rec := range reader.Records() {
+		if rec.Err != nil {
+			err = writer.WriteRejected(rec)
+		} else {
+			err = writer.Write(rec, []solver.Result{{X: 1.5, Steps: 3, Method: "newton"}})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"Id,N,M,K,A,B,Tol,MaxIter,Algorithm,Precision,Criterion,Timeout,X,Steps,Method,Error,tag",
+		// Job cells are echoed as read, absent ones taken from the defaults
+		"1,2,2,1,0.5,10,1e-09,0,,0,,,1.5,3,newton,<nil>,first",
+	}
+	if len(lines) != 3 {
+		t.Fatalf("wrote %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i], line)
+		}
+	}
+	if !strings.HasPrefix(lines[2], "2,") || !strings.HasSuffix(lines[2], ",bad") {
+		t.Errorf("rejected row = %q, want its id and extra column", lines[2])
+	}
+}
@@ -0,0 +1,230 @@
+// Package jobfile reads jobs and writes their results in the file formats
+// shared by the poweq CLI and API: CSV, JSON arrays, NDJSON, Arrow IPC
+// and Parquet files and, for results only, aligned tables. Files are
+// streamed one job at a time, Arrow and Parquet ones one record batch at
+// a time.
+package jobfile
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// File formats for jobs and results
+const (
+	FORMAT_CSV     = "csv"
+	FORMAT_JSON    = "json"    // A single JSON array
+	FORMAT_NDJSON  = "ndjson"  // One JSON object per line
+	FORMAT_TABLE   = "table"   // Aligned columns for terminals, output only
+	FORMAT_ARROW   = "arrow"   // Arrow IPC file, columns as in CSV
+	FORMAT_PARQUET = "parquet" // Columns as in Arrow
+)
+
+var (
+	InputFormats  = []string{FORMAT_CSV, FORMAT_JSON, FORMAT_NDJSON, FORMAT_ARROW, FORMAT_PARQUET}
+	OutputFormats = []string{FORMAT_CSV, FORMAT_JSON, FORMAT_NDJSON, FORMAT_ARROW, FORMAT_PARQUET, FORMAT_TABLE}
+)
+
+// Reader yields the jobs of an input file, whatever its format.
+// Like bufio.Scanner, a fatal read error stops the iteration and is
+// reported by Err once the loop is over.
+type Reader interface {
+	Records() iter.Seq[Record]
+	// Skip consumes the next n records and returns the raw Id of the last one.
+	Skip(n int) (string, error)
+	// ExtraColumns returns the names of the passthrough values, those of
+	// the first job for JSON. It must be called before any record is read.
+	ExtraColumns() []string
+	Err() error
+}
+
+// Writer writes the results of each job as soon as they are produced.
+type Writer interface {
+	WriteHeader() error
+	Write(rec Record, results []solver.Result) error
+	WriteRejected(rec Record) error
+	// Flush pushes buffered data to the file, leaving it ready for more.
+	Flush() error
+	// Close flushes and writes what ends the file, if anything.
+	Close() error
+}
+
+// NewReader creates a reader for format, CSV unless it is JSON, NDJSON,
+// Arrow or Parquet.
+func NewReader(r io.Reader, format string, opts Options) (Reader, error) {
+	switch format {
+	case FORMAT_JSON:
+		return newJSONJobReader(r, opts)
+	case FORMAT_NDJSON:
+		return newNDJSONJobReader(r, opts), nil
+	case FORMAT_ARROW:
+		return newArrowJobReader(r, opts)
+	case FORMAT_PARQUET:
+		return newParquetJobReader(r, opts)
+	default:
+		return newCSVJobReader(r, opts)
+	}
+}
+
+// NewWriter creates a writer for format. written is the number of
+// jobs already in the file when appending to it on resume.
+func NewWriter(w io.Writer, format string, opts Options, extraNames []string, written int) Writer {
+	switch format {
+	case FORMAT_JSON:
+		return newJSONResultWriter(w, true, written, opts.Digits)
+	case FORMAT_NDJSON:
+		return newJSONResultWriter(w, false, written, opts.Digits)
+	case FORMAT_TABLE:
+		return newTableResultWriter(w, opts.Digits)
+	case FORMAT_ARROW:
+		return newArrowResultWriter(w, opts, extraNames)
+	case FORMAT_PARQUET:
+		return newParquetResultWriter(w, opts, extraNames)
+	default:
+		return newCSVResultWriter(w, opts, extraNames)
+	}
+}
+
+// JobWriter writes jobs rather than results, for the tools that produce
+// the input of solve and scan.
+type JobWriter interface {
+	WriteJob(rec Record) error
+	// Close flushes and writes the file footer.
+	Close() error
+}
+
+// NewJobWriter creates a writer of jobs for the Arrow and Parquet formats,
+// with the job columns of a CSV input then the passthrough columns
+// extraNames. Text formats need no library and are written directly.
+func NewJobWriter(w io.Writer, format string, extraNames []string) (JobWriter, error) {
+	columns := slices.Concat(RequiredColumns, OptionalColumns)
+	switch format {
+	case FORMAT_ARROW:
+		return newBatchResultWriter(w, Options{}, columns, extraNames, openArrowFile), nil
+	case FORMAT_PARQUET:
+		return newBatchResultWriter(w, Options{}, columns, extraNames, openParquetFile), nil
+	}
+	return nil, fmt.Errorf("unsupported jobs format %q", format)
+}
+
+// ExtraValue returns the passthrough value of rec named name, whatever
+// its case.
+func (rec Record) ExtraValue(name string) (string, bool) {
+	for i, extraName := range rec.ExtraNames {
+		if strings.EqualFold(extraName, name) && i < len(rec.Extra) {
+			return rec.Extra[i], true
+		}
+	}
+	return "", false
+}
+
+// record turns a JSON request into a job record, filling the optional
+// fields left empty with the defaults, as for CSV cells.
+func (d Defaults) record(req solver.SolveRequest) (Record, error) {
+	if req.Tolerance == 0 {
+		req.Tolerance = d.Tol
+	}
+	if req.MaxIter == 0 {
+		req.MaxIter = d.MaxIter
+	}
+	if req.Precision == 0 {
+		req.Precision = d.Precision
+	}
+	rec := Record{Algorithm: strings.ToLower(req.Algorithm)}
+	if rec.Algorithm == "" {
+		rec.Algorithm = d.Algorithm
+	} else if !solver.IsMethod(rec.Algorithm) {
+		return rec, fmt.Errorf("field \"algorithm\": unknown algorithm %q", req.Algorithm)
+	}
+	if req.Criterion == "" {
+		req.Criterion = d.Criterion
+	} else if !solver.IsCriterion(strings.ToLower(req.Criterion)) {
+		return rec, fmt.Errorf("field \"criterion\": unknown stopping criterion %q", req.Criterion)
+	}
+	req.Criterion = strings.ToLower(req.Criterion)
+
+	job, err := req.Job()
+	if err != nil {
+		return rec, err
+	}
+	if req.Timeout == "" {
+		job.Timeout = d.Timeout
+	}
+	rec.Job = job
+
+	for _, name := range slices.Sorted(maps.Keys(req.Extra)) {
+		rec.ExtraNames = append(rec.ExtraNames, name)
+		rec.Extra = append(rec.Extra, req.Extra[name])
+	}
+	return rec, nil
+}
+
+// response builds the JSON form of a job and its results.
+func response(rec Record, results []solver.Result) solver.SolveResponse {
+	resp := solver.NewSolveResponse(rec.Job.Id, results)
+	req := solver.NewSolveRequest(rec.Job, rec.Algorithm)
+	if len(rec.Extra) > 0 {
+		req.Extra = make(map[string]string, len(rec.Extra))
+		for i, name := range rec.ExtraNames {
+			if i < len(rec.Extra) {
+				req.Extra[name] = rec.Extra[i]
+			}
+		}
+	}
+	resp.Job = &req
+	return resp
+}
+
+// tableResultWriter aligns results in columns for reading in a terminal.
+// Alignment needs whole columns, so rows are only written out on Flush.
+type tableResultWriter struct {
+	writer *tabwriter.Writer
+	digits int
+}
+
+func newTableResultWriter(w io.Writer, digits int) *tableResultWriter {
+	return &tableResultWriter{writer: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), digits: digits}
+}
+
+func (tw *tableResultWriter) WriteHeader() error {
+	_, err := fmt.Fprintln(tw.writer, "ID\tN\tM\tK\tA\tB\tALGORITHM\tX\tSTEPS\tMETHOD\tERROR")
+	return err
+}
+
+func (tw *tableResultWriter) Write(rec Record, results []solver.Result) error {
+	// Id to B, then the algorithm
+	cells := JobCells(rec)
+	params := strings.Join(append(cells[:6], rec.Algorithm), "\t")
+	for _, result := range results {
+		errText := ""
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		_, err := fmt.Fprintf(tw.writer, "%s\t%s\t%d\t%s\t%s\n",
+			params, solver.FormatFloat(result.X, tw.digits), result.Steps, result.Method, errText)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tw *tableResultWriter) WriteRejected(rec Record) error {
+	_, err := fmt.Fprintf(tw.writer, "%s\t\t\t\t\t\t\t\t\t\trejected (line %d): %v\n", rec.RawId, rec.Line, rec.Err)
+	return err
+}
+
+func (tw *tableResultWriter) Flush() error {
+	return tw.writer.Flush()
+}
+
+func (tw *tableResultWriter) Close() error {
+	return tw.writer.Flush()
+}
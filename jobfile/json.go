@@ -0,0 +1,344 @@
+package jobfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Fields a JSON job must have, zero being a valid value for some of them
+var requiredFields = []string{"n", "m", "k", "a", "b"}
+
+const MAX_NDJSON_LINE = 1 << 20
+
+// decodeRequest parses one JSON job. Unknown fields are rejected in strict
+// mode, like unknown CSV columns.
+// A response is accepted too, its job is read back, so that results can be
+// solved again.
+func decodeRequest(data []byte, strict bool) (solver.SolveRequest, error) {
+	var req solver.SolveRequest
+	var resp struct {
+		Job json.RawMessage `json:"job"`
+	}
+	if json.Unmarshal(data, &resp) == nil && len(resp.Job) > 0 {
+		return decodeRequest(resp.Job, false)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&req); err != nil {
+		return req, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return req, err
+	}
+	for _, name := range requiredFields {
+		if _, ok := fields[name]; !ok {
+			return req, fmt.Errorf("missing required field %q", name)
+		}
+	}
+	return req, nil
+}
+
+// rawId extracts the id of a JSON job as written, even if the job is invalid.
+func rawId(data []byte) string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+	return string(fields["id"])
+}
+
+// jsonRecord builds the record of the job at position pos (1-based).
+// Jobs without an id are numbered by their position.
+func jsonRecord(data []byte, pos int, opts Options) Record {
+	req, err := decodeRequest(data, opts.StrictColumns)
+	if err == nil && req.Id == 0 {
+		req.Id = pos
+	}
+	rec := Record{}
+	if err == nil {
+		rec, err = opts.Defaults.record(req)
+	}
+	rec.Line, rec.Raw, rec.Err = pos, []string{string(data)}, err
+	if rec.RawId = rawId(data); rec.RawId == "" {
+		rec.RawId = strconv.Itoa(pos)
+	}
+	return rec
+}
+
+// extraKeys returns the names of the extra fields of a JSON job, sorted,
+// nil when it cannot be decoded.
+func extraKeys(data []byte) []string {
+	req, err := decodeRequest(data, false)
+	if err != nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(req.Extra))
+}
+
+// ndjsonJobReader reads one job per line, blank lines are ignored.
+type ndjsonJobReader struct {
+	scanner *bufio.Scanner
+	opts    Options
+	line    int
+	err     error
+
+	head   []byte   // First job, read ahead by ExtraColumns
+	extra  []string // Extra fields of the first job
+	peeked bool
+}
+
+func newNDJSONJobReader(r io.Reader, opts Options) *ndjsonJobReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MAX_NDJSON_LINE)
+	return &ndjsonJobReader{scanner: scanner, opts: opts}
+}
+
+// next returns the next non blank line.
+func (nr *ndjsonJobReader) next() ([]byte, bool) {
+	if nr.head != nil {
+		line := nr.head
+		nr.head = nil
+		return line, true
+	}
+	for nr.scanner.Scan() {
+		nr.line++
+		if line := bytes.TrimSpace(nr.scanner.Bytes()); len(line) > 0 {
+			return line, true
+		}
+	}
+	nr.err = nr.scanner.Err()
+	return nil, false
+}
+
+func (nr *ndjsonJobReader) Records() iter.Seq[Record] {
+	return func(yield func(Record) bool) {
+		for {
+			line, ok := nr.next()
+			if !ok {
+				return
+			}
+			if !yield(jsonRecord(line, nr.line, nr.opts)) {
+				return
+			}
+		}
+	}
+}
+
+func (nr *ndjsonJobReader) Skip(n int) (string, error) {
+	var lastId string
+	for i := 0; i < n; i++ {
+		line, ok := nr.next()
+		if !ok {
+			if nr.err != nil {
+				return lastId, nr.err
+			}
+			return lastId, fmt.Errorf("input has only %d jobs, expected at least %d", i, n)
+		}
+		if lastId = rawId(line); lastId == "" {
+			lastId = strconv.Itoa(nr.line)
+		}
+	}
+	return lastId, nil
+}
+
+// ExtraColumns returns the extra fields of the first job, which must be
+// called before anything is read.
+func (nr *ndjsonJobReader) ExtraColumns() []string {
+	if !nr.peeked {
+		nr.peeked = true
+		if line, ok := nr.next(); ok {
+			// The scanner reuses its buffer
+			nr.head = bytes.Clone(line)
+			nr.extra = extraKeys(nr.head)
+		}
+	}
+	return nr.extra
+}
+
+func (nr *ndjsonJobReader) Err() error {
+	return nr.err
+}
+
+// jsonJobReader streams the elements of a JSON array of jobs,
+// decoding one element at a time.
+type jsonJobReader struct {
+	decoder *json.Decoder
+	opts    Options
+	pos     int
+	err     error
+
+	head   json.RawMessage // First job, read ahead by ExtraColumns
+	extra  []string        // Extra fields of the first job
+	peeked bool
+}
+
+func newJSONJobReader(r io.Reader, opts Options) (*jsonJobReader, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, errors.New("input file is empty, expected a JSON array")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("expected a JSON array of jobs")
+	}
+	return &jsonJobReader{decoder: decoder, opts: opts}, nil
+}
+
+// next returns the next element of the array. A syntax error ends the
+// stream since the decoder cannot find the next element after it.
+func (jr *jsonJobReader) next() (json.RawMessage, bool) {
+	if jr.head != nil {
+		raw := jr.head
+		jr.head = nil
+		return raw, true
+	}
+	if !jr.decoder.More() {
+		return nil, false
+	}
+	var raw json.RawMessage
+	if err := jr.decoder.Decode(&raw); err != nil {
+		jr.err = fmt.Errorf("job %d: %w", jr.pos+1, err)
+		return nil, false
+	}
+	jr.pos++
+	return raw, true
+}
+
+func (jr *jsonJobReader) Records() iter.Seq[Record] {
+	return func(yield func(Record) bool) {
+		for {
+			raw, ok := jr.next()
+			if !ok {
+				return
+			}
+			if !yield(jsonRecord(raw, jr.pos, jr.opts)) {
+				return
+			}
+		}
+	}
+}
+
+func (jr *jsonJobReader) Skip(n int) (string, error) {
+	var lastId string
+	for i := 0; i < n; i++ {
+		raw, ok := jr.next()
+		if !ok {
+			if jr.err != nil {
+				return lastId, jr.err
+			}
+			return lastId, fmt.Errorf("input has only %d jobs, expected at least %d", i, n)
+		}
+		if lastId = rawId(raw); lastId == "" {
+			lastId = strconv.Itoa(jr.pos)
+		}
+	}
+	return lastId, nil
+}
+
+// ExtraColumns returns the extra fields of the first job, which must be
+// called before anything is read.
+func (jr *jsonJobReader) ExtraColumns() []string {
+	if !jr.peeked {
+		jr.peeked = true
+		if raw, ok := jr.next(); ok {
+			jr.head = raw
+			jr.extra = extraKeys(raw)
+		}
+	}
+	return jr.extra
+}
+
+func (jr *jsonJobReader) Err() error {
+	return jr.err
+}
+
+// jsonResultWriter writes one response object per job, either one per
+// line (NDJSON) or as the elements of a single array.
+// The closing bracket of the array is only written by Close, so that a
+// checkpoint taken after Flush can be appended to.
+type jsonResultWriter struct {
+	writer  *bufio.Writer
+	array   bool
+	written int
+	digits  int
+}
+
+func newJSONResultWriter(w io.Writer, array bool, written, digits int) *jsonResultWriter {
+	return &jsonResultWriter{writer: bufio.NewWriter(w), array: array, written: written, digits: digits}
+}
+
+func (jw *jsonResultWriter) WriteHeader() error {
+	if jw.array {
+		_, err := jw.writer.WriteString("[\n")
+		return err
+	}
+	return nil
+}
+
+func (jw *jsonResultWriter) encode(resp solver.SolveResponse) error {
+	if jw.array && jw.written > 0 {
+		if _, err := jw.writer.WriteString(",\n"); err != nil {
+			return err
+		}
+	}
+	jw.written++
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if _, err = jw.writer.Write(data); err != nil {
+		return err
+	}
+	// Inside an array the newline comes with the next separator
+	if !jw.array {
+		err = jw.writer.WriteByte('\n')
+	}
+	return err
+}
+
+func (jw *jsonResultWriter) Write(rec Record, results []solver.Result) error {
+	resp := response(rec, results)
+	resp.Round(jw.digits)
+	return jw.encode(resp)
+}
+
+func (jw *jsonResultWriter) WriteRejected(rec Record) error {
+	id, _ := strconv.Atoi(rec.RawId)
+	resp := solver.SolveResponse{
+		Id:        id,
+		Solutions: []solver.Solution{},
+		Error:     fmt.Sprintf("rejected (line %d): %v", rec.Line, rec.Err),
+	}
+	return jw.encode(resp)
+}
+
+func (jw *jsonResultWriter) Flush() error {
+	return jw.writer.Flush()
+}
+
+func (jw *jsonResultWriter) Close() error {
+	if jw.array {
+		if _, err := jw.writer.WriteString("\n]\n"); err != nil {
+			return err
+		}
+	}
+	return jw.writer.Flush()
+}
@@ -0,0 +1,50 @@
+package jobfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// newParquetJobReader reads a Parquet file through its Arrow schema, row
+// group after row group, with the column mapping of Arrow files.
+func newParquetJobReader(r io.Reader, opts Options) (*arrowJobReader, error) {
+	input, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := file.NewParquetReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("not a Parquet file: %w", err)
+	}
+	props := pqarrow.ArrowReadProperties{BatchSize: ARROW_BATCH_ROWS}
+	fr, err := pqarrow.NewFileReader(pf, props, memory.NewGoAllocator())
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file: %w", err)
+	}
+	schema, err := fr.Schema()
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	records, err := fr.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchJobReader(records, schema, opts)
+}
+
+// newParquetResultWriter writes results as Parquet, one row group per
+// record batch, with the column types of Arrow output.
+func newParquetResultWriter(w io.Writer, opts Options, extraNames []string) *arrowResultWriter {
+	return newBatchResultWriter(w, opts, resultFileColumns(), extraNames, openParquetFile)
+}
+
+func openParquetFile(w io.Writer, schema *arrow.Schema) (batchWriter, error) {
+	return pqarrow.NewFileWriter(schema, w, parquet.NewWriterProperties(), pqarrow.DefaultWriterProps())
+}
@@ -0,0 +1,111 @@
+package jobfile
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// ErrRejected is wrapped by the errors of a scan stopped on rejected rows.
+var ErrRejected = errors.New("rejected")
+
+// ScanCounts counts the rows of a scan.
+type ScanCounts struct {
+	Jobs     int // Input rows processed, rejected ones included
+	Results  int
+	Rejected int
+}
+
+// Scanner solves the rows of a jobs file and writes their results in
+// input order, as poweq scan and the scan endpoint do.
+type Scanner struct {
+	Workers    int
+	Cache      *solver.Cache // Results of jobs already solved, nil to solve every job
+	Slots      chan struct{} // Held while solving a job, nil for no limit
+	Strict     bool          // Stop at the first rejected row
+	MaxRejects int           // 0 for no limit
+	Rejects    *RejectWriter // Rejected rows are also written there, when set
+	FlushRows  int           // Rows between flushes, 0 to flush at the end only
+	OnRow      func(rec Record, results []solver.Result)
+	OnFlush    func(counts ScanCounts, complete bool) error // After the writers are flushed
+}
+
+// Run writes the results of the rows of reader to writer, adding to
+// counts. Rows are no longer read once ctx is done. The writers are
+// flushed every FlushRows rows and once more at the end, even on error,
+// but writer is left open. The row that stops a scan is not written, so
+// that a resumed run reads it again.
+func (s Scanner) Run(ctx context.Context, reader Reader, writer Writer, counts *ScanCounts) error {
+	records := func(yield func(Record) bool) {
+		for rec := range reader.Records() {
+			if ctx.Err() != nil || !yield(rec) {
+				return
+			}
+		}
+	}
+
+	var scanErr error
+	sinceFlush := 0
+	for rec, results := range solveOrdered(records, s.Workers, s.Cache, s.Slots) {
+		if rec.Err != nil {
+			if s.Strict {
+				scanErr = fmt.Errorf("line %d %w: %w", rec.Line, ErrRejected, rec.Err)
+				break
+			}
+			if s.MaxRejects > 0 && counts.Rejected >= s.MaxRejects {
+				scanErr = fmt.Errorf("too many %w rows: more than %d", ErrRejected, s.MaxRejects)
+				break
+			}
+			if s.Rejects != nil {
+				if scanErr = s.Rejects.Write(rec); scanErr != nil {
+					break
+				}
+			}
+			if scanErr = writer.WriteRejected(rec); scanErr != nil {
+				break
+			}
+			counts.Rejected++
+		} else if scanErr = writer.Write(rec, results); scanErr != nil {
+			break
+		}
+
+		counts.Jobs++
+		counts.Results += len(results)
+		if s.OnRow != nil {
+			s.OnRow(rec, results)
+		}
+
+		if sinceFlush++; s.FlushRows > 0 && sinceFlush >= s.FlushRows {
+			sinceFlush = 0
+			if scanErr = s.flush(writer, *counts, false); scanErr != nil {
+				break
+			}
+		}
+	}
+	if scanErr == nil {
+		scanErr = cmp.Or(reader.Err(), ctx.Err())
+	}
+	if err := s.flush(writer, *counts, scanErr == nil); err != nil && scanErr == nil {
+		scanErr = err
+	}
+	return scanErr
+}
+
+// flush writes buffered rows, then calls OnFlush.
+func (s Scanner) flush(writer Writer, counts ScanCounts, complete bool) error {
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if s.Rejects != nil {
+		if err := s.Rejects.Flush(); err != nil {
+			return err
+		}
+	}
+	if s.OnFlush == nil {
+		return nil
+	}
+	return s.OnFlush(counts, complete)
+}
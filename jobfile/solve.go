@@ -0,0 +1,101 @@
+package jobfile
+
+import (
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// SolveOrdered solves records on a pool of workers and yields them back
+// in input order, rejected records passing through with no results.
+// Jobs found in cache, when set, are not solved again.
+// At most 2*workers records are in flight, so memory stays bounded and
+// everything yielded so far is a contiguous prefix of the input.
+func SolveOrdered(records iter.Seq[Record], workers int, cache *solver.Cache) iter.Seq2[Record, []solver.Result] {
+	return solveOrdered(records, workers, cache, nil)
+}
+
+// solveOrdered is SolveOrdered, each job holding one of slots, when set,
+// while it is solved.
+func solveOrdered(records iter.Seq[Record], workers int, cache *solver.Cache, slots chan struct{}) iter.Seq2[Record, []solver.Result] {
+	solve := func(rec *Record) []solver.Result {
+		if rec.Err != nil {
+			return nil
+		}
+		if slots != nil {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+		}
+		start := time.Now()
+		results := cache.Process(rec.Job, rec.Algorithm)
+		rec.Elapsed = time.Since(start)
+		return results
+	}
+
+	if workers <= 1 {
+		return func(yield func(Record, []solver.Result) bool) {
+			for rec := range records {
+				results := solve(&rec)
+				if !yield(rec, results) {
+					return
+				}
+			}
+		}
+	}
+
+	return func(yield func(Record, []solver.Result) bool) {
+		// Workers set rec.Elapsed before sending the results, which are
+		// received before rec is read
+		type item struct {
+			rec     *Record
+			results chan []solver.Result
+		}
+		work := make(chan item)
+		pending := make(chan item, 2*workers)
+		done := make(chan struct{})
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for it := range work {
+					it.results <- solve(it.rec)
+				}
+			}()
+		}
+
+		// The producer owns the records iterator until it returns
+		produced := make(chan struct{})
+		go func() {
+			defer close(produced)
+			defer close(pending)
+			defer close(work)
+			for rec := range records {
+				it := item{rec: &rec, results: make(chan []solver.Result, 1)}
+				select {
+				case pending <- it:
+				case <-done:
+					return
+				}
+				select {
+				case work <- it:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for it := range pending {
+			results := <-it.results
+			if !yield(*it.rec, results) {
+				break
+			}
+		}
+		close(done)
+		<-produced
+		wg.Wait()
+	}
+}
@@ -0,0 +1,81 @@
+package jobfile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// testRecords yields n records with ids 1 to n, every rejectEvery-th one
+// rejected.
+func testRecords(n, rejectEvery int) func(yield func(Record) bool) {
+	return func(yield func(Record) bool) {
+		for id := 1; id <= n; id++ {
+			rec := Record{
+				Job:       solver.Job{Id: id, N: 2, M: 2, K: float64(id%5 + 1), A: 0.5, B: 10, Tol: 1e-9, MaxIter: 100},
+				Algorithm: solver.METHOD_AUTO,
+				Line:      id + 1,
+			}
+			if rejectEvery > 0 && id%rejectEvery == 0 {
+				rec.Err = errors.New("rejected")
+			}
+			if !yield(rec) {
+				return
+			}
+		}
+	}
+}
+
+func TestSolveOrdered(t *testing.T) {
+	tests := []struct {
+		workers int
+		n       int
+		stop    int  // Records read before breaking out, 0 to read them all
+		cache   bool // Jobs repeat every 5 ids, so most are found in cache
+	}{
+		{workers: 1, n: 50},
+		{workers: 2, n: 50},
+		{workers: 4, n: 200},
+		{workers: 8, n: 200, cache: true},
+		{workers: 8, n: 3},
+		{workers: 4, n: 200, stop: 10},
+		{workers: 0, n: 10, cache: true},
+	}
+	for _, tt := range tests {
+		var cache *solver.Cache
+		if tt.cache {
+			var err error
+			if cache, err = solver.NewCache(0, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var ids []int
+		for rec, results := range SolveOrdered(testRecords(tt.n, 7), tt.workers, cache) {
+			ids = append(ids, rec.Job.Id)
+			if rec.Err != nil {
+				if results != nil {
+					t.Errorf("workers %d: rejected record %d has results", tt.workers, rec.Job.Id)
+				}
+			} else if len(results) == 0 || results[0].Id != rec.Job.Id {
+				t.Errorf("workers %d: record %d has results %+v", tt.workers, rec.Job.Id, results)
+			}
+			if len(ids) == tt.stop {
+				break
+			}
+		}
+
+		want := tt.n
+		if tt.stop > 0 {
+			want = tt.stop
+		}
+		if len(ids) != want {
+			t.Fatalf("workers %d: got %d records, want %d", tt.workers, len(ids), want)
+		}
+		for i, id := range ids {
+			if id != i+1 {
+				t.Fatalf("workers %d: record %d has id %d, out of order", tt.workers, i+1, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package plot
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Marks of the ASCII plot
+var curveMarks = []byte{'*', '#'} // f or ln(x^n), then ln(K m^x)
+
+// ASCII draws the plot in width by height characters: the curves with
+// '*' and '#', the axis with '-', x_limit with '|' and the roots with 'o'.
+// The line under the plot marks the initial guesses with '^'.
+func (p Plot) ASCII(w io.Writer, width, height int) error {
+	if width < 2 || height < 2 {
+		return fmt.Errorf("%w: %dx%d characters", ErrTooSmall, width, height)
+	}
+	curves := p.curves(width)
+	yMin, yMax := bounds(curves)
+	row := func(y float64) int {
+		i := int(math.Round((yMax - y) / (yMax - yMin) * float64(height-1)))
+		return min(max(i, 0), height-1)
+	}
+	column := func(x float64) (int, bool) {
+		t := p.position(x)
+		return int(math.Round(t * float64(width-1))), t >= 0 && t <= 1
+	}
+
+	grid := make([][]byte, height)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", width))
+	}
+	axis := row(0)
+	for j := range width {
+		grid[axis][j] = '-'
+	}
+	if top, ok := column(p.XLimit); ok {
+		for i := range grid {
+			grid[i][top] = '|'
+		}
+	}
+	for c, ys := range curves {
+		for j, y := range ys {
+			switch {
+			case math.IsInf(y, -1):
+				grid[height-1][j] = 'v' // ln(0), below the plot
+			case finite(y):
+				grid[row(y)][j] = curveMarks[c]
+			}
+		}
+	}
+	for _, x := range p.Roots {
+		if j, ok := column(x); ok {
+			grid[row(p.value(x))][j] = 'o'
+		}
+	}
+	guesses := []byte(strings.Repeat(" ", width))
+	for _, x := range p.Guesses {
+		if j, ok := column(x); ok {
+			guesses[j] = '^'
+		}
+	}
+
+	fmt.Fprintf(w, "%10s  %s\n", "", p.title())
+	labels := map[int]string{0: formatTick(yMax), axis: "0", height - 1: formatTick(yMin)}
+	for i, line := range grid {
+		if _, err := fmt.Fprintf(w, "%10s |%s\n", labels[i], line); err != nil {
+			return err
+		}
+	}
+	if len(p.Guesses) > 0 {
+		fmt.Fprintf(w, "%10s  %s\n", "", strings.TrimRight(string(guesses), " "))
+	}
+	from, to := formatTick(p.From), formatTick(p.To)
+	fmt.Fprintf(w, "%10s  %s%*s\n", "", from, width-len(from), to)
+	_, err := fmt.Fprintf(w, "%10s  %s, x on a %s scale\n", "", legend(p, "* ln(x^n), # ln(K m^x)", "| x_limit, o roots, ^ initial guesses"), p.scale())
+	return err
+}
+
+// legend names the curves, then the marks.
+func legend(p Plot, sides, marks string) string {
+	if p.Sides {
+		return sides + ", " + marks
+	}
+	return marks
+}
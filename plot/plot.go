@@ -0,0 +1,181 @@
+// Package plot draws f(x) = n ln(x) - ln(K) - x ln(m), whose roots solve
+// x^n = K m^x, as ASCII for terminals or as SVG. The closed-form roots,
+// x_limit, the top of f, and the initial guesses of Newton are marked.
+package plot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/AbdallahZerfaoui/poweq/solver"
+)
+
+// Default sizes, in characters for ASCII and in pixels for SVG
+const (
+	ASCII_WIDTH  = 72
+	ASCII_HEIGHT = 20
+	SVG_WIDTH    = 800
+	SVG_HEIGHT   = 480
+)
+
+// Scales of the x axis
+const (
+	SCALE_AUTO   = "auto"
+	SCALE_LINEAR = "linear"
+	SCALE_LOG    = "log"
+)
+
+var Scales = []string{SCALE_AUTO, SCALE_LINEAR, SCALE_LOG}
+
+// ErrTooSmall is returned when the size asked for leaves no room to draw.
+var ErrTooSmall = errors.New("plot is too small")
+
+// LOG_SCALE_RATIO is the ratio of the bounds above which the auto scale
+// is logarithmic, the roots near 0 being lost otherwise.
+const LOG_SCALE_RATIO = 1e3
+
+// Plot is a job drawn over [From, To].
+type Plot struct {
+	Job      solver.Job
+	From, To float64
+	Log      bool      // x on a log scale
+	Sides    bool      // Draw ln(x^n) and ln(K m^x) instead of f, their difference
+	Roots    []float64 // Closed-form roots in [From, To]
+	Guesses  []float64 // Initial guesses of Newton in [From, To]
+	XLimit   float64   // Top of f, NaN when f has none (m = 1 or n = 0)
+}
+
+// New prepares the plot of job over [from, to], on the auto scale.
+func New(job solver.Job, from, to float64) (Plot, error) {
+	if !finite(from) || !finite(to) || from < 0 || from >= to {
+		return Plot{}, fmt.Errorf("invalid range [%g, %g], expected 0 <= from < to", from, to)
+	}
+	p := Plot{Job: job, From: from, To: to, XLimit: math.NaN()}
+	p.SetScale(SCALE_AUTO)
+
+	view := job
+	view.A, view.B = from, to
+	p.Roots = view.ExactRoots()
+	if job.M != 1 && job.N != 0 {
+		p.XLimit = job.N / math.Log(job.M)
+		// Edge cases are solved in closed form, without guesses
+		for _, x := range job.GetInitValues() {
+			if x >= from && x <= to {
+				p.Guesses = append(p.Guesses, x)
+			}
+		}
+	}
+	return p, nil
+}
+
+// SetScale sets the scale of x: log needs From > 0, auto picks log when
+// the range spans more than LOG_SCALE_RATIO.
+func (p *Plot) SetScale(scale string) error {
+	switch scale {
+	case SCALE_AUTO:
+		p.Log = p.From > 0 && p.To/p.From >= LOG_SCALE_RATIO
+	case SCALE_LINEAR:
+		p.Log = false
+	case SCALE_LOG:
+		if p.From <= 0 {
+			return errors.New("a log scale needs from > 0")
+		}
+		p.Log = true
+	default:
+		return fmt.Errorf("unknown scale %q, expected auto, linear or log", scale)
+	}
+	return nil
+}
+
+func (p Plot) scale() string {
+	if p.Log {
+		return SCALE_LOG
+	}
+	return SCALE_LINEAR
+}
+
+// at returns x at position t in [0, 1].
+func (p Plot) at(t float64) float64 {
+	if p.Log {
+		return math.Exp(math.Log(p.From) + t*(math.Log(p.To)-math.Log(p.From)))
+	}
+	return p.From + t*(p.To-p.From)
+}
+
+// position is the inverse of at, outside [0, 1] when x is out of range.
+func (p Plot) position(x float64) float64 {
+	if p.Log {
+		return (math.Log(x) - math.Log(p.From)) / (math.Log(p.To) - math.Log(p.From))
+	}
+	return (x - p.From) / (p.To - p.From)
+}
+
+// Sides of the equation, in log form
+func lhs(job solver.Job, x float64) float64 {
+	if job.N == 0 {
+		return 0 // x^0, even at x = 0
+	}
+	return job.N * math.Log(x)
+}
+
+func rhs(job solver.Job, x float64) float64 {
+	return math.Log(job.K) + x*math.Log(job.M)
+}
+
+// curves samples the curves at count points: f, or both sides.
+func (p Plot) curves(count int) [][]float64 {
+	n := 1
+	if p.Sides {
+		n = 2
+	}
+	curves := make([][]float64, n)
+	for i := range curves {
+		curves[i] = make([]float64, count)
+	}
+	for j := range count {
+		x := p.at(float64(j) / float64(count-1))
+		if p.Sides {
+			curves[0][j], curves[1][j] = lhs(p.Job, x), rhs(p.Job, x)
+		} else {
+			curves[0][j] = lhs(p.Job, x) - rhs(p.Job, x)
+		}
+	}
+	return curves
+}
+
+// value is the height of the marks of x: f = 0, or both sides when they
+// cross.
+func (p Plot) value(x float64) float64 {
+	if p.Sides {
+		return rhs(p.Job, x)
+	}
+	return 0
+}
+
+// bounds is the range of the finite values, 0 included for the axis.
+func bounds(curves [][]float64) (yMin, yMax float64) {
+	for _, ys := range curves {
+		for _, y := range ys {
+			if finite(y) {
+				yMin, yMax = math.Min(yMin, y), math.Max(yMax, y)
+			}
+		}
+	}
+	if yMin == yMax {
+		yMin, yMax = yMin-1, yMax+1
+	}
+	return yMin, yMax
+}
+
+func (p Plot) title() string {
+	return fmt.Sprintf("x^n = K m^x with n = %s, m = %s, K = %s", formatTick(p.Job.N), formatTick(p.Job.M), formatTick(p.Job.K))
+}
+
+func finite(y float64) bool {
+	return !math.IsInf(y, 0) && !math.IsNaN(y)
+}
+
+func formatTick(x float64) string {
+	return solver.FormatFloat(x, 4)
+}
@@ -0,0 +1,104 @@
+package plot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Margins of the SVG plot, in pixels, around the drawing area
+const (
+	svgMarginLeft   = 70
+	svgMarginRight  = 20
+	svgMarginTop    = 40
+	svgMarginBottom = 70
+)
+
+// svgSamples is the number of points of each curve.
+const svgSamples = 400
+
+// Colors of the curves: f or ln(x^n), then ln(K m^x)
+var curveColors = []string{"#1f77b4", "#d62728"}
+
+type legendItem struct{ color, text string }
+
+// SVG draws the plot as a standalone SVG image of width by height pixels,
+// with the same marks as ASCII.
+func (p Plot) SVG(w io.Writer, width, height int) error {
+	if width <= svgMarginLeft+svgMarginRight || height <= svgMarginTop+svgMarginBottom {
+		return fmt.Errorf("%w: %dx%d pixels", ErrTooSmall, width, height)
+	}
+	left, right := float64(svgMarginLeft), float64(width-svgMarginRight)
+	top, bottom := float64(svgMarginTop), float64(height-svgMarginBottom)
+	curves := p.curves(svgSamples)
+	yMin, yMax := bounds(curves)
+	px := func(x float64) float64 { return left + p.position(x)*(right-left) }
+	py := func(y float64) float64 { return top + (yMax-y)/(yMax-yMin)*(bottom-top) }
+	visible := func(x float64) bool { t := p.position(x); return t >= 0 && t <= 1 }
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(bw, `<text x="%d" y="20" text-anchor="middle" font-size="14">%s</text>`+"\n", width/2, p.title())
+
+	// Frame, axis and ticks
+	fmt.Fprintf(bw, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#999"/>`+"\n", left, top, right-left, bottom-top)
+	fmt.Fprintf(bw, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n", left, py(0), right, py(0))
+	for _, y := range slices.Compact([]float64{yMax, 0, yMin}) {
+		fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", left-6, py(y), formatTick(y))
+	}
+	for _, t := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		x := p.at(t)
+		fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", px(x), bottom+24, formatTick(x))
+	}
+	fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" text-anchor="middle">x (%s scale)</text>`+"\n", (left+right)/2, bottom+42, p.scale())
+
+	if visible(p.XLimit) {
+		fmt.Fprintf(bw, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#2ca02c" stroke-dasharray="6 4"><title>x_limit = %s</title></line>`+"\n",
+			px(p.XLimit), top, px(p.XLimit), bottom, formatTick(p.XLimit))
+	}
+
+	// Curves, split where they are not finite
+	for c, ys := range curves {
+		var segment []string
+		flush := func() {
+			if len(segment) > 1 {
+				fmt.Fprintf(bw, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", curveColors[c], strings.Join(segment, " "))
+			}
+			segment = segment[:0]
+		}
+		for j, y := range ys {
+			if !finite(y) {
+				flush()
+				continue
+			}
+			x := p.at(float64(j) / float64(len(ys)-1))
+			segment = append(segment, fmt.Sprintf("%.1f,%.1f", px(x), py(y)))
+		}
+		flush()
+	}
+
+	for _, x := range p.Guesses {
+		fmt.Fprintf(bw, `<path d="M %.1f %.1f l -5 10 h 10 z" fill="#ff7f0e"><title>initial guess %s</title></path>`+"\n", px(x), bottom, formatTick(x))
+	}
+	for _, x := range p.Roots {
+		y := math.Max(yMin, math.Min(yMax, p.value(x)))
+		fmt.Fprintf(bw, `<circle cx="%.1f" cy="%.1f" r="4" fill="white" stroke="black" stroke-width="2"><title>root %s</title></circle>`+"\n", px(x), py(y), formatTick(x))
+	}
+
+	legend := []legendItem{{curveColors[0], "f(x) = n ln(x) - ln(K) - x ln(m)"}}
+	if p.Sides {
+		legend = []legendItem{{curveColors[0], "ln(x^n)"}, {curveColors[1], "ln(K m^x)"}}
+	}
+	legend = append(legend, legendItem{"#2ca02c", "x_limit"}, legendItem{"#ff7f0e", "initial guesses"}, legendItem{"black", "roots"})
+	x := left
+	for _, item := range legend {
+		fmt.Fprintf(bw, `<rect x="%.1f" y="%.1f" width="10" height="10" fill="%s"/><text x="%.1f" y="%.1f">%s</text>`+"\n", x, float64(height)-14, item.color, x+14, float64(height)-5, item.text)
+		x += 14 + 7*float64(len(item.text)) + 16
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
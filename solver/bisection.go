@@ -10,21 +10,29 @@ import (
 func BisectionSolve(job Job, lower float64, upper float64) Result {
 	// a, b := job.A, job.B
 	n, m := job.N, job.M
-	K, tol, maxIter := job.K, job.Tol, job.MaxIter
+	K, maxIter := job.K, job.MaxIter
+	trace := tracing()
 
 	fa := f(lower, n, m, K)
 	fb := f(upper, n, m, K)
 
 	if fa*fb > 0 {
-		return Result{job.Id, 0, 0, errors.New("f(a) and f(b) must have opposite signs")}
+		return Result{Id: job.Id, X: 0, Steps: 0, Method: METHOD_BISECTION, Err: errors.New("f(a) and f(b) must have opposite signs")}
 	}
 
 	for i := range maxIter {
+		if job.expired() {
+			return Result{Id: job.Id, X: 0, Steps: i, Method: METHOD_BISECTION, Err: ErrTimeout}
+		}
+
 		c := (lower + upper) / 2
 		fc := f(c, n, m, K)
+		if trace {
+			logJob(LEVEL_TRACE, job, METHOD_BISECTION, "Iteration", "i", i+1, "lower", lower, "upper", upper, "x", c, "f", fc)
+		}
 
-		if math.Abs(fc) < tol || (upper-lower)/2 < tol {
-			return Result{job.Id, c, i + 1, nil}
+		if job.converged(CRITERION_ANY, c, (upper-lower)/2, fc) {
+			return Result{Id: job.Id, X: c, Steps: i + 1, Method: METHOD_BISECTION, Err: nil}
 		}
 
 		if fa*fc < 0 {
@@ -35,7 +43,7 @@ func BisectionSolve(job Job, lower float64, upper float64) Result {
 		fa = f(lower, n, m, K) // Update fa for the new interval
 	}
 
-	return Result{job.Id, 0, 0, errors.New("maximum iterations reached without convergence")}
+	return Result{Id: job.Id, X: 0, Steps: 0, Method: METHOD_BISECTION, Err: errors.New("maximum iterations reached without convergence")}
 }
 
 func getIntervals(job Job) [][2]float64 {
@@ -0,0 +1,269 @@
+package solver
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DEFAULT_CACHE_SIZE is the number of jobs kept in memory by a cache.
+const DEFAULT_CACHE_SIZE = 10000
+
+// CACHE_VERSION is part of every cache key. Bump it when a change to the
+// solver alters its results or when the stored entries change, so that
+// entries left on disk by older versions are not reused.
+const CACHE_VERSION = 1
+
+// CacheKey identifies a job by the parameters its results depend on,
+// whatever its Id, and by the version of the solver.
+type CacheKey struct {
+	Version   int
+	N, M, K   float64
+	A, B      float64
+	Tol       float64
+	MaxIter   int
+	Precision int
+	Criterion string
+	Timeout   time.Duration
+	Method    string
+}
+
+// KeyOf returns the cache key of solving job with method.
+func KeyOf(job Job, method string) CacheKey {
+	return CacheKey{
+		N: job.N, M: job.M, K: job.K, A: job.A, B: job.B, Tol: job.Tol,
+		MaxIter: job.MaxIter, Precision: job.Precision,
+		Criterion: job.Criterion, Timeout: job.Timeout, Method: method,
+		Version: CACHE_VERSION,
+	}
+}
+
+// CacheStats counts the lookups of a cache since it was created.
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"` // In memory
+}
+
+// Cache keeps the results of solved jobs, so that jobs with the same
+// parameters are not solved again. The most recently used entries are
+// kept in memory; with a directory, every entry is also stored on disk,
+// one file per job, and outlives the process. A nil Cache solves every
+// job. A Cache is safe for concurrent use.
+type Cache struct {
+	size int
+	dir  string // Empty for memory only
+
+	mu      sync.Mutex
+	order   *list.List // Of *cacheEntry, most recently used first
+	entries map[CacheKey]*list.Element
+
+	hits, misses atomic.Int64
+}
+
+type cacheEntry struct {
+	key     CacheKey
+	results []Result
+}
+
+// NewCache creates a cache keeping size jobs in memory, DEFAULT_CACHE_SIZE
+// if size is not positive, and storing them in dir unless it is empty.
+func NewCache(size int, dir string) (*Cache, error) {
+	if size <= 0 {
+		size = DEFAULT_CACHE_SIZE
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("cache directory: %w", err)
+		}
+	}
+	return &Cache{size: size, dir: dir, order: list.New(), entries: map[CacheKey]*list.Element{}}, nil
+}
+
+// Solve is job.Solve, through the cache.
+// Results hitting a timeout are not cached, as they depend on the load
+// of the machine, nor those of invalid jobs.
+func (c *Cache) Solve(job Job, method string) []Result {
+	if c == nil {
+		return job.Solve(method)
+	}
+	key := KeyOf(job, method)
+	if key != key || job.Validate() != nil {
+		// With NaN parameters, the key would never be found again
+		return job.Solve(method)
+	}
+	if results, ok := c.get(key); ok {
+		c.hits.Add(1)
+		logJob(LEVEL_TRACE, job, method, "Cache hit")
+		return withId(results, job.Id)
+	}
+	c.misses.Add(1)
+
+	results := job.Solve(method)
+	if !slices.ContainsFunc(results, func(r Result) bool { return errors.Is(r.Err, ErrTimeout) }) {
+		c.put(key, withId(results, 0))
+	}
+	return results
+}
+
+// Process is job.Process, solving through the cache.
+func (c *Cache) Process(job Job, method string) []Result {
+	return job.process(method, c.Solve)
+}
+
+// Stats returns the lookups so far and the size of the cache.
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: c.order.Len()}
+}
+
+// withId copies results with their Id set to id.
+func withId(results []Result, id int) []Result {
+	copied := make([]Result, len(results))
+	for i, result := range results {
+		result.Id = id
+		copied[i] = result
+	}
+	return copied
+}
+
+// get looks key up in memory, then on disk.
+func (c *Cache) get(key CacheKey) ([]Result, bool) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).results, true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, false
+	}
+	results, err := c.load(key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Load().Warn("Cannot read cache entry", "error", err)
+		}
+		return nil, false
+	}
+	c.remember(key, results)
+	return results, true
+}
+
+// put stores the results of key in memory and on disk.
+func (c *Cache) put(key CacheKey, results []Result) {
+	c.remember(key, results)
+	if c.dir == "" {
+		return
+	}
+	if err := c.store(key, results); err != nil {
+		logger.Load().Warn("Cannot write cache entry", "error", err)
+	}
+}
+
+// remember adds an entry in memory, evicting the least recently used one
+// when full.
+func (c *Cache) remember(key CacheKey, results []Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).results = results
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, results})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// storedResult is a Result as saved on disk, its error as text.
+type storedResult struct {
+	X      float64 `json:"x"`
+	Steps  int     `json:"steps"`
+	Method string  `json:"method,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// storedEntry is the file of a cache entry. The key is kept in it, the
+// name of the file being only its hash.
+type storedEntry struct {
+	Key     CacheKey       `json:"key"`
+	Results []storedResult `json:"results"`
+}
+
+func (c *Cache) path(key CacheKey) string {
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) load(key CacheKey) ([]Result, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry storedEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if entry.Key != key {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	results := make([]Result, len(entry.Results))
+	for i, r := range entry.Results {
+		results[i] = Result{X: r.X, Steps: r.Steps, Method: r.Method}
+		if r.Error != "" {
+			results[i].Err = errors.New(r.Error)
+		}
+	}
+	return results, nil
+}
+
+// store writes an entry through a temporary file, so that concurrent
+// readers never see it half written.
+func (c *Cache) store(key CacheKey, results []Result) error {
+	entry := storedEntry{Key: key, Results: make([]storedResult, len(results))}
+	for i, r := range results {
+		entry.Results[i] = storedResult{X: r.X, Steps: r.Steps, Method: r.Method}
+		if r.Err != nil {
+			entry.Results[i].Error = r.Err.Error()
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
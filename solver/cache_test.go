@@ -0,0 +1,163 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cacheJob is a valid job told apart from the others by K.
+func cacheJob(id int, K float64) Job {
+	return Job{Id: id, N: 2, M: 2, K: K, A: 0, B: 10, Tol: 1e-9, MaxIter: 100}
+}
+
+func newTestCache(t *testing.T, size int, dir string) *Cache {
+	t.Helper()
+	c, err := NewCache(size, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCacheLRU(t *testing.T) {
+	c := newTestCache(t, 2, "")
+	steps := []struct {
+		K   float64
+		hit bool
+	}{
+		{1, false},
+		{2, false},
+		{1, true},
+		{3, false}, // Evicts 2, the least recently used
+		{1, true},
+		{2, false}, // Evicts 3
+		{3, false},
+		{2, true},
+	}
+	for i, step := range steps {
+		before := c.Stats()
+		results := c.Solve(cacheJob(i+1, step.K), METHOD_NEWTON)
+		after := c.Stats()
+		if hit := after.Hits > before.Hits; hit != step.hit {
+			t.Errorf("step %d, K = %v: hit = %v, want %v", i+1, step.K, hit, step.hit)
+		}
+		if after.Entries > 2 {
+			t.Errorf("step %d: %d entries, more than the size", i+1, after.Entries)
+		}
+		if len(results) == 0 || results[0].Id != i+1 {
+			t.Errorf("step %d: results %+v, want the id of the job", i+1, results)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 3 || stats.Misses != 5 {
+		t.Errorf("Stats() = %+v, want 3 hits and 5 misses", stats)
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	nan := cacheJob(1, 1)
+	nan.A = math.NaN()
+	timeout := cacheJob(1, 1)
+	timeout.Timeout = time.Nanosecond
+	tests := []struct {
+		name string
+		job  Job
+	}{
+		{"invalid job", Job{N: 2, M: 0.5, K: 1, A: 0.1, B: 100, Tol: 1e-9, MaxIter: 100}},
+		{"NaN parameter", nan},
+		{"timeout", timeout},
+	}
+	for _, tt := range tests {
+		c := newTestCache(t, 0, "")
+		results := c.Solve(tt.job, METHOD_NEWTON)
+		if tt.name == "timeout" && !slices.ContainsFunc(results, func(r Result) bool { return errors.Is(r.Err, ErrTimeout) }) {
+			t.Logf("%s: solved before the deadline", tt.name)
+			continue
+		}
+		if stats := c.Stats(); stats.Entries != 0 {
+			t.Errorf("%s: cached, %+v", tt.name, stats)
+		}
+	}
+
+	// A nil Cache solves every job
+	var c *Cache
+	if results := c.Solve(cacheJob(1, 1), METHOD_NEWTON); len(results) == 0 || results[0].Err != nil {
+		t.Errorf("nil Cache: results %+v", results)
+	}
+	if stats := c.Stats(); stats != (CacheStats{}) {
+		t.Errorf("nil Cache: Stats() = %+v", stats)
+	}
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	jobs := []Job{
+		cacheJob(1, 1),
+		cacheJob(2, 100), // No solution, the error is stored as text
+	}
+	first := newTestCache(t, 0, dir)
+	var want [][]Result
+	for _, job := range jobs {
+		want = append(want, first.Solve(job, METHOD_BISECTION))
+	}
+
+	// Another process finds the entries on disk
+	second := newTestCache(t, 1, dir)
+	for i, job := range jobs {
+		job.Id += 10
+		got := second.Solve(job, METHOD_BISECTION)
+		if len(got) != len(want[i]) {
+			t.Fatalf("job %d: %d results from disk, want %d", i+1, len(got), len(want[i]))
+		}
+		for j := range got {
+			w := want[i][j]
+			if got[j].Id != job.Id || got[j].X != w.X || got[j].Steps != w.Steps || got[j].Method != w.Method ||
+				(got[j].Err == nil) != (w.Err == nil) || (w.Err != nil && got[j].Err.Error() != w.Err.Error()) {
+				t.Errorf("job %d: result from disk %+v, want %+v", i+1, got[j], w)
+			}
+		}
+	}
+	if stats := second.Stats(); stats.Hits != 2 || stats.Misses != 0 {
+		t.Errorf("Stats() = %+v, want 2 hits", stats)
+	}
+
+	// A corrupt entry is solved again and replaced
+	key := KeyOf(jobs[0], METHOD_BISECTION)
+	if err := os.WriteFile(first.path(key), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	third := newTestCache(t, 0, dir)
+	if got := third.Solve(jobs[0], METHOD_BISECTION); len(got) != len(want[0]) || got[0].X != want[0][0].X {
+		t.Errorf("corrupt entry: results %+v, want %+v", got, want[0])
+	}
+	if stats := third.Stats(); stats.Misses != 1 {
+		t.Errorf("corrupt entry: Stats() = %+v, want a miss", stats)
+	}
+	if _, err := third.load(key); err != nil {
+		t.Errorf("corrupt entry not replaced: %v", err)
+	}
+}
+
+func TestCacheVersion(t *testing.T) {
+	dir := t.TempDir()
+	job := cacheJob(1, 1)
+	first := newTestCache(t, 0, dir)
+	results := first.Solve(job, METHOD_NEWTON)
+
+	// Entries stored by another version of the solver are not reused
+	key := KeyOf(job, METHOD_NEWTON)
+	if err := os.Remove(first.path(key)); err != nil {
+		t.Fatal(err)
+	}
+	key.Version++
+	first.put(key, results)
+
+	second := newTestCache(t, 0, dir)
+	second.Solve(job, METHOD_NEWTON)
+	if stats := second.Stats(); stats.Hits != 0 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want a miss", stats)
+	}
+}
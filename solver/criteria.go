@@ -0,0 +1,59 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+// Stopping criteria
+// The step is |x1 - x0| for Newton and the half-width of the bracket for bisection,
+// the residual is |f(x)|.
+const (
+	CRITERION_DEFAULT  = ""         // Newton: step, bisection: any
+	CRITERION_STEP     = "step"     // Stop when the step is below tolerance
+	CRITERION_RESIDUAL = "residual" // Stop when the residual is below tolerance
+	CRITERION_BOTH     = "both"     // Stop when both are below tolerance
+	CRITERION_ANY      = "any"      // Stop when either is below tolerance
+)
+
+var Criteria = []string{CRITERION_STEP, CRITERION_RESIDUAL, CRITERION_BOTH, CRITERION_ANY}
+
+var ErrTimeout = errors.New("timeout reached without convergence")
+
+// IsCriterion reports whether name is a valid stopping criterion, "" included.
+func IsCriterion(name string) bool {
+	return name == CRITERION_DEFAULT || slices.Contains(Criteria, name)
+}
+
+// converged applies the job's stopping criterion, falling back to def
+// when the job does not set one.
+func (job Job) converged(def string, x, step, residual float64) bool {
+	stepOk := step < job.Tol
+	if job.Precision > 0 {
+		// Relative test: |dx| < |x| * 10^-precision
+		stepOk = step < math.Abs(x)*math.Pow(10, -float64(job.Precision))
+	}
+	residualOk := math.Abs(residual) < job.Tol
+
+	criterion := job.Criterion
+	if criterion == CRITERION_DEFAULT {
+		criterion = def
+	}
+	switch criterion {
+	case CRITERION_RESIDUAL:
+		return residualOk
+	case CRITERION_BOTH:
+		return stepOk && residualOk
+	case CRITERION_ANY:
+		return stepOk || residualOk
+	default:
+		return stepOk
+	}
+}
+
+// expired reports whether the job ran past its timeout.
+func (job Job) expired() bool {
+	return !job.deadline.IsZero() && time.Now().After(job.deadline)
+}
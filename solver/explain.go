@@ -0,0 +1,197 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+)
+
+// Edge cases, solved in closed form
+const (
+	EDGE_M_ONE     = "m = 1"
+	EDGE_N_ZERO    = "n = 0"
+	EDGE_M_ONE_N_0 = "m = 1 and n = 0"
+)
+
+// Explanation is the analysis behind the solving of a job: the equation
+// in the form the methods work on, where its roots lie and how the methods
+// start. Values that do not apply to the job are left out of the JSON.
+type Explanation struct {
+	Id             int           `json:"id"`
+	Equation       string        `json:"equation"`   // x^n = K m^x with the values of the job
+	LogForm        string        `json:"log_form"`   // n ln(x) = ln(K) + x ln(m)
+	Function       string        `json:"function"`   // f(x), whose roots are the solutions
+	Derivative     string        `json:"derivative"` // f'(x)
+	EdgeCase       string        `json:"edge_case,omitempty"`
+	XLimit         *float64      `json:"x_limit,omitempty"`   // Top of f, where f'(x) = 0
+	FXLimit        *float64      `json:"f_x_limit,omitempty"` // f(x_limit), solutions exist when it is not below 0
+	SolutionsExist bool          `json:"solutions_exist"`
+	ExpectedRoots  int           `json:"expected_roots"`            // In [A, B]
+	Reasoning      []string      `json:"reasoning"`                 // How ExpectedRoots follows from the shape of f
+	Analytic       *float64      `json:"analytic,omitempty"`        // Closed-form root of an edge case, which skips the methods
+	InitialGuesses []float64     `json:"initial_guesses,omitempty"` // Starting points of Newton
+	Intervals      [][2]float64  `json:"intervals,omitempty"`       // Brackets of bisection
+	LambertW       *LambertWForm `json:"lambert_w,omitempty"`
+	ExactRoots     []float64     `json:"exact_roots"` // Closed-form roots in [A, B]
+}
+
+// LambertWForm is the closed form x = -W(z) / c, with c = ln(m)/n and
+// z = -c K^(1/n). Both real branches exist when -1/e <= z < 0.
+type LambertWForm struct {
+	C      float64  `json:"c"`
+	Z      float64  `json:"z"`
+	W0     *float64 `json:"w0,omitempty"`
+	Wm1    *float64 `json:"w_minus_1,omitempty"`
+	Root0  *float64 `json:"root_w0,omitempty"`        // Smaller root, -W0(z)/c, in [A, B] or not
+	RootM1 *float64 `json:"root_w_minus_1,omitempty"` // Larger root, -W-1(z)/c
+}
+
+// Explain analyses the job without solving it. The job is expected to be
+// valid.
+func (job Job) Explain() Explanation {
+	n, m, K := job.N, job.M, job.K
+	num := func(x float64) string { return FormatFloat(x, DIGITS_EXACT) }
+	e := Explanation{
+		Id:         job.Id,
+		Equation:   fmt.Sprintf("x^%s = %s * %s^x", num(n), num(K), num(m)),
+		LogForm:    fmt.Sprintf("%s ln(x) = %s %s x", num(n), num(math.Log(K)), signed(math.Log(m))),
+		Function:   fmt.Sprintf("f(x) = %s ln(x) %s %s x", num(n), signed(-math.Log(K)), signed(-math.Log(m))),
+		Derivative: fmt.Sprintf("f'(x) = %s/x %s", num(n), signed(-math.Log(m))),
+		ExactRoots: append([]float64{}, job.ExactRoots()...),
+	}
+	e.SolutionsExist = job.SolutionsExist()
+
+	switch {
+	case m == 1 && n == 0:
+		e.EdgeCase = EDGE_M_ONE_N_0
+	case m == 1:
+		e.EdgeCase = EDGE_M_ONE
+	case n == 0:
+		e.EdgeCase = EDGE_N_ZERO
+	default:
+		xLimit := n / math.Log(m)
+		fLimit := f(xLimit, n, m, K)
+		e.XLimit, e.FXLimit = &xLimit, &fLimit
+		e.LambertW = job.lambertW()
+	}
+	e.ExpectedRoots = job.countRoots(func(format string, args ...any) {
+		e.Reasoning = append(e.Reasoning, fmt.Sprintf(format, args...))
+	})
+
+	// As in Solve: an edge case with its root in [A, B] skips the methods
+	if done, x := job.handleEdgeCases(); done && x != -1.0 {
+		e.Analytic = &x
+	} else {
+		e.InitialGuesses = job.GetInitValues()
+		e.Intervals = getIntervals(job)
+	}
+	return e
+}
+
+// signed writes a term of a sum: "+ x" or "- |x|".
+func signed(x float64) string {
+	if math.Signbit(x) {
+		return "- " + FormatFloat(-x, DIGITS_EXACT)
+	}
+	return "+ " + FormatFloat(x, DIGITS_EXACT)
+}
+
+// exact is a number formatted by %s in its shortest exact form, only
+// when the text is actually built.
+type exact float64
+
+func (x exact) String() string {
+	return FormatFloat(float64(x), DIGITS_EXACT)
+}
+
+// CountRoots counts the roots in [A, B] from the shape of f, which is
+// concave with its top at x_limit. It is the ExpectedRoots of Explain.
+func (job Job) CountRoots() int {
+	return job.countRoots(func(string, ...any) {})
+}
+
+// countRoots counts the roots in [A, B], passing each step of the
+// reasoning to explain.
+func (job Job) countRoots(explain func(format string, args ...any)) int {
+	n, m, K, a, b := job.N, job.M, job.K, exact(job.A), exact(job.B)
+	inRange := func(x float64, why string) int {
+		explain(why)
+		if x >= job.A && x <= job.B {
+			explain("x = %s lies in [%s, %s]", exact(x), a, b)
+			return 1
+		}
+		explain("x = %s lies outside [%s, %s]", exact(x), a, b)
+		return 0
+	}
+
+	switch {
+	case m == 1 && n == 0:
+		if K == 1 {
+			explain("f(x) = 0 for every x: every x is a solution, there is no isolated root")
+		} else {
+			explain("f(x) = -ln(K) is a non-zero constant: there is no solution")
+		}
+		return 0
+	case m == 1:
+		return inRange(math.Pow(K, 1/n), "f(x) = n ln(x) - ln(K) is increasing, with the single root x = K^(1/n)")
+	case n == 0:
+		return inRange(-math.Log(K)/math.Log(m), "f(x) = -ln(K) - x ln(m) is decreasing, with the single root x = -ln(K)/ln(m)")
+	}
+
+	xLimit := n / math.Log(m)
+	fLimit, fa, fb := f(xLimit, n, m, K), f(job.A, n, m, K), f(job.B, n, m, K)
+	explain("f is concave with its top at x_limit = n/ln(m) = %s", exact(xLimit))
+	if fLimit < 0 {
+		explain("f(x_limit) = %s < 0: f stays below 0, no solution exists", exact(fLimit))
+		return 0
+	}
+	explain("f(x_limit) = %s >= 0: f has a root on each side of x_limit", exact(fLimit))
+	count := 0
+	switch {
+	case xLimit <= job.A:
+		explain("x_limit <= a: f is decreasing on [a, b], only the right root can lie in it")
+		if fa >= 0 && fb <= 0 {
+			count = 1
+		}
+		explain("f(a) = %s and f(b) = %s", exact(fa), exact(fb))
+	case xLimit >= job.B:
+		explain("x_limit >= b: f is increasing on [a, b], only the left root can lie in it")
+		if fa <= 0 && fb >= 0 {
+			count = 1
+		}
+		explain("f(a) = %s and f(b) = %s", exact(fa), exact(fb))
+	default:
+		explain("x_limit lies in [a, b]")
+		if fa <= 0 {
+			count++
+			explain("f(a) = %s <= 0: the left root lies in [a, x_limit]", exact(fa))
+		} else {
+			explain("f(a) = %s > 0: the left root lies below a", exact(fa))
+		}
+		if fb <= 0 {
+			count++
+			explain("f(b) = %s <= 0: the right root lies in [x_limit, b]", exact(fb))
+		} else {
+			explain("f(b) = %s > 0: the right root lies above b", exact(fb))
+		}
+	}
+	return count
+}
+
+// lambertW gives the closed form of the roots, in [A, B] or not, nil
+// when z overflows.
+func (job Job) lambertW() *LambertWForm {
+	c := math.Log(job.M) / job.N
+	form := &LambertWForm{C: c, Z: -c * math.Exp(math.Log(job.K)/job.N)}
+	if math.IsInf(form.Z, 0) || math.IsNaN(form.Z) {
+		return nil
+	}
+	if w0, err := LambertW0(form.Z); err == nil {
+		root := -w0 / c
+		form.W0, form.Root0 = &w0, &root
+	}
+	if wm1, err := LambertWm1(form.Z); err == nil {
+		root := -wm1 / c
+		form.Wm1, form.RootM1 = &wm1, &root
+	}
+	return form
+}
@@ -0,0 +1,50 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Significant digits of output numbers. With DIGITS_EXACT numbers are
+// written with the fewest digits that read back as the same float64.
+const (
+	DIGITS_EXACT = 0
+	MAX_DIGITS   = 17 // Enough for any float64
+)
+
+// CheckDigits validates a number of significant digits.
+func CheckDigits(digits int) error {
+	if digits < 0 || digits > MAX_DIGITS {
+		return fmt.Errorf("digits must be between 0 and %d, got %d", MAX_DIGITS, digits)
+	}
+	return nil
+}
+
+// FormatFloat writes x with the given number of significant digits.
+func FormatFloat(x float64, digits int) string {
+	if digits == DIGITS_EXACT {
+		digits = -1
+	}
+	return strconv.FormatFloat(x, 'g', digits, 64)
+}
+
+// RoundFloat rounds x to the given number of significant digits, for
+// formats such as JSON that write numbers themselves.
+func RoundFloat(x float64, digits int) float64 {
+	if digits == DIGITS_EXACT || math.IsInf(x, 0) || math.IsNaN(x) {
+		return x
+	}
+	rounded, err := strconv.ParseFloat(FormatFloat(x, digits), 64)
+	if err != nil {
+		return x
+	}
+	return rounded
+}
+
+// Round rounds the roots of a response. Job parameters are left as given.
+func (resp *SolveResponse) Round(digits int) {
+	for i := range resp.Solutions {
+		resp.Solutions[i].X = RoundFloat(resp.Solutions[i].X, digits)
+	}
+}
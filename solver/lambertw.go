@@ -0,0 +1,112 @@
+package solver
+
+import (
+	"errors"
+	"math"
+)
+
+// Closed form of the roots with the Lambert W function.
+// x^n = K m^x  <=>  -cx e^(-cx) = -c K^(1/n)  with c = ln(m)/n
+// so x = -W(-c K^(1/n)) / c, W0 giving the smaller root and W-1 the larger.
+// Both branches are real when -1/e <= -c K^(1/n) < 0.
+
+var ErrLambertDomain = errors.New("lambert W: argument out of domain")
+
+const lambertMaxIter = 100
+
+// LambertW0 is the principal branch of W on [-1/e, 0], W0(x) in [-1, 0].
+func LambertW0(x float64) (float64, error) {
+	if x < -1/math.E || x > 0 || math.IsNaN(x) {
+		return math.NaN(), ErrLambertDomain
+	}
+	if x == 0 {
+		return 0, nil
+	}
+	w := x
+	if x < -0.25 {
+		w = branchPointSeries(x, 1)
+	}
+	return halley(x, w), nil
+}
+
+// LambertWm1 is the lower branch of W on [-1/e, 0), W-1(x) <= -1.
+func LambertWm1(x float64) (float64, error) {
+	if x < -1/math.E || x >= 0 || math.IsNaN(x) {
+		return math.NaN(), ErrLambertDomain
+	}
+	if x < -0.25 {
+		return halley(x, branchPointSeries(x, -1)), nil
+	}
+	// Far from the branch point W-1 goes to -Inf while w e^w underflows,
+	// so solve w + ln(-w) = ln(-x) instead
+	l := math.Log(-x)
+	w := l - math.Log(-l)
+	for i := 0; i < lambertMaxIter; i++ {
+		next := w - (w+math.Log(-w)-l)/(1+1/w)
+		if math.Abs(next-w) <= 1e-15*math.Abs(next) {
+			return next, nil
+		}
+		w = next
+	}
+	return w, nil
+}
+
+// branchPointSeries starts both branches near x = -1/e, where
+// W = -1 + p - p^2/3 + 11/72 p^3 with p = ±sqrt(2(ex + 1)).
+func branchPointSeries(x float64, sign float64) float64 {
+	p := sign * math.Sqrt(math.Max(0, 2*(math.E*x+1)))
+	return -1 + p - p*p/3 + 11.0/72*p*p*p
+}
+
+// halley refines w as a root of w e^w - x.
+func halley(x, w float64) float64 {
+	for i := 0; i < lambertMaxIter; i++ {
+		ew := math.Exp(w)
+		f := w*ew - x
+		if w == -1 || f == 0 {
+			return w
+		}
+		next := w - f/(ew*(w+1)-(w+2)*f/(2*w+2))
+		if math.Abs(next-w) <= 1e-15*math.Max(1, math.Abs(next)) {
+			return next
+		}
+		w = next
+	}
+	return w
+}
+
+// ExactRoots gives the roots of the job in [A, B] from the closed form,
+// in increasing order. They serve as reference answers for testing the
+// iterative methods.
+func (job Job) ExactRoots() []float64 {
+	var roots []float64
+	keep := func(x float64) {
+		if x >= job.A && x <= job.B && !math.IsNaN(x) {
+			roots = append(roots, x)
+		}
+	}
+
+	switch {
+	case job.M == 1 && job.N == 0: // K = 1 holds for every x, there is no isolated root
+	case job.M == 1:
+		keep(math.Pow(job.K, 1/job.N))
+	case job.N == 0:
+		keep(-math.Log(job.K) / math.Log(job.M))
+	default:
+		c := math.Log(job.M) / job.N
+		arg := -c * math.Exp(math.Log(job.K)/job.N)
+		// Rounding may push a double root just past the branch point
+		if arg < -1/math.E && arg > -1/math.E*(1+1e-12) {
+			arg = -1 / math.E
+		}
+		w0, err := LambertW0(arg)
+		if err != nil {
+			return nil
+		}
+		keep(-w0 / c)
+		if wm1, err := LambertWm1(arg); err == nil && wm1 != w0 {
+			keep(-wm1 / c)
+		}
+	}
+	return roots
+}
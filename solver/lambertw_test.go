@@ -0,0 +1,104 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// near tells whether got is want to within a relative tolerance.
+func near(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol*math.Max(1, math.Abs(want))
+}
+
+func TestLambertW(t *testing.T) {
+	tests := []struct {
+		name string
+		w    func(float64) (float64, error)
+		x    float64
+		want float64
+	}{
+		{"W0 at 0", LambertW0, 0, 0},
+		{"W0 at the branch point", LambertW0, -1 / math.E, -1},
+		{"W0 of -ln2/2", LambertW0, -math.Ln2 / 2, -math.Ln2},
+		{"W0 of -0.1", LambertW0, -0.1, -0.11183255915896297},
+		{"W0 of -0.3", LambertW0, -0.3, -0.48940222718021487},
+		{"W0 near 0", LambertW0, -1e-300, -1e-300},
+		{"W-1 at the branch point", LambertWm1, -1 / math.E, -1},
+		{"W-1 of -ln2/2", LambertWm1, -math.Ln2 / 2, -2 * math.Ln2},
+		{"W-1 of -ln3/3", LambertWm1, -math.Log(3) / 3, -math.Log(3)},
+		{"W-1 of -0.1", LambertWm1, -0.1, -3.577152063957297},
+		{"W-1 of -0.3", LambertWm1, -0.3, -1.7813370234216275},
+		{"W-1 of -1e-10", LambertWm1, -1e-10, -26.295238819246926},
+	}
+	for _, tt := range tests {
+		got, err := tt.w(tt.x)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		// The branch point is a double root, found to half precision
+		tol := 1e-14
+		if tt.x == -1/math.E {
+			tol = 1e-7
+		}
+		if !near(got, tt.want, tol) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLambertWDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		w    func(float64) (float64, error)
+		x    float64
+	}{
+		{"W0 above 0", LambertW0, 0.1},
+		{"W0 below -1/e", LambertW0, -0.5},
+		{"W0 of NaN", LambertW0, math.NaN()},
+		{"W-1 at 0", LambertWm1, 0},
+		{"W-1 below -1/e", LambertWm1, -1},
+		{"W-1 of NaN", LambertWm1, math.NaN()},
+	}
+	for _, tt := range tests {
+		got, err := tt.w(tt.x)
+		if !errors.Is(err, ErrLambertDomain) || !math.IsNaN(got) {
+			t.Errorf("%s = %v, %v, want NaN, ErrLambertDomain", tt.name, got, err)
+		}
+	}
+}
+
+func TestExactRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		want []float64
+		tol  float64 // Relative, 1e-12 if zero
+	}{
+		{"x^2 = 2^x", Job{N: 2, M: 2, K: 1, A: 0, B: 10}, []float64{2, 4}, 0},
+		{"x^3 = 3^x", Job{N: 3, M: 3, K: 1, A: 0, B: 10}, []float64{2.4780526802883016, 3}, 0},
+		{"bound included", Job{N: 2, M: 2, K: 1, A: 2, B: 3}, []float64{2}, 0},
+		{"other root in range", Job{N: 2, M: 2, K: 1, A: 3, B: 5}, []float64{4}, 0},
+		// A double root, given once and to half precision
+		{"tangent", Job{N: 1, M: math.E, K: 1 / math.E, A: 0, B: 10}, []float64{1}, 1e-7},
+		{"no crossing", Job{N: 2, M: 2, K: 100, A: 0, B: 10}, nil, 0},
+		{"m = 1", Job{N: 2, M: 1, K: 9, A: 0, B: 10}, []float64{3}, 0},
+		{"n = 0", Job{N: 0, M: 2, K: 0.25, A: 0, B: 10}, []float64{2}, 0},
+		{"n = 0 and m = 1", Job{N: 0, M: 1, K: 1, A: 0, B: 10}, nil, 0},
+	}
+	for _, tt := range tests {
+		tol := tt.tol
+		if tol == 0 {
+			tol = 1e-12
+		}
+		got := tt.job.ExactRoots()
+		ok := len(got) == len(tt.want)
+		for i := 0; ok && i < len(got); i++ {
+			ok = near(got[i], tt.want[i], tol)
+		}
+		if !ok {
+			t.Errorf("%s: ExactRoots() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package solver
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// LEVEL_TRACE logs every iteration of the methods, below debug.
+const LEVEL_TRACE = slog.LevelDebug - 4
+
+// logger receives the logs of the solver. They are discarded unless a
+// handler is set, so the solver stays silent when used as a library.
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(slog.DiscardHandler))
+}
+
+// SetLogHandler sends the logs of the solver to h, nil discarding them.
+func SetLogHandler(h slog.Handler) {
+	if h == nil {
+		h = slog.DiscardHandler
+	}
+	logger.Store(slog.New(h))
+}
+
+// LogHandler returns the handler of the solver logs.
+func LogHandler() slog.Handler {
+	return logger.Load().Handler()
+}
+
+// tracing reports whether iterations are logged. Methods check it once,
+// so that the arguments of each iteration are only built when needed.
+func tracing() bool {
+	return logger.Load().Enabled(context.Background(), LEVEL_TRACE)
+}
+
+// logJob logs about a job, with its id and method as attributes. Nothing
+// is allocated when level is disabled, solving being on the hot path.
+func logJob(level slog.Level, job Job, method, msg string, args ...any) {
+	l := logger.Load()
+	if !l.Enabled(context.Background(), level) {
+		return
+	}
+	l.With("job", job.Id, "method", method).Log(context.Background(), level, msg, args...)
+}
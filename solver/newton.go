@@ -9,32 +9,40 @@ import (
 func NewtonSolve(job Job, x0 float64) Result {
 	a, b := job.A, job.B
 	n, m := job.N, job.M
-	K, tol, maxIter := job.K, job.Tol, job.MaxIter
+	K, maxIter := job.K, job.MaxIter
+	trace := tracing()
 
 	for i := range maxIter {
+		if job.expired() {
+			return Result{Id: job.Id, X: 0, Steps: i, Method: METHOD_NEWTON, Err: ErrTimeout}
+		}
+
 		fx := f(x0, n, m, K)
 		fpx := fPrime(x0, n, m)
 
 		if fpx == 0 {
-			return Result{job.Id, 0, i, errors.New("derivative is zero")}
+			return Result{Id: job.Id, X: 0, Steps: i, Method: METHOD_NEWTON, Err: errors.New("derivative is zero")}
 		}
 
 		x1 := x0 - fx/fpx // Newton-Raphson update
+		if trace {
+			logJob(LEVEL_TRACE, job, METHOD_NEWTON, "Iteration", "i", i+1, "x", x1, "f", fx, "f'", fpx, "step", math.Abs(x1-x0))
+		}
 
-		if math.Abs(x1-x0) < tol {
+		if job.converged(CRITERION_STEP, x1, math.Abs(x1-x0), f(x1, n, m, K)) {
 			// We check only the last value to see if it's within bounds
 			// because if the initial guess is within bounds and the method converges,
 			// it should remain within bounds.
 			// However, if an intermediate value is out of bounds, we might still converge to a valid solution.
 			// Thus, we only check the final result.
 			if x1 < a || x1 > b {
-				return Result{job.Id, 0, i + 1, errors.New("solution out of bounds")}
+				return Result{Id: job.Id, X: 0, Steps: i + 1, Method: METHOD_NEWTON, Err: errors.New("solution out of bounds")}
 			}
-			return Result{job.Id, x1, i + 1, nil}
+			return Result{Id: job.Id, X: x1, Steps: i + 1, Method: METHOD_NEWTON, Err: nil}
 		}
 
 		x0 = x1
 	}
 
-	return Result{job.Id, 0, maxIter, errors.New("maximum iterations reached without convergence")}
+	return Result{Id: job.Id, X: 0, Steps: maxIter, Method: METHOD_NEWTON, Err: errors.New("maximum iterations reached without convergence")}
 }
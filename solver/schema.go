@@ -0,0 +1,87 @@
+package solver
+
+import (
+	"fmt"
+	"time"
+)
+
+// JSON schema of jobs and results, shared by the CLI and the REST API.
+// Field names are part of the public interface: add fields, never rename them.
+
+type SolveRequest struct {
+	Id        int               `json:"id,omitempty" example:"1"`
+	N         float64           `json:"n" binding:"required" example:"2"`
+	M         float64           `json:"m" binding:"required" example:"2.718281828"`
+	K         float64           `json:"k" binding:"required" example:"1"`
+	A         float64           `json:"a" binding:"required" example:"0.1"`
+	B         float64           `json:"b" binding:"required" example:"10"`
+	Tolerance float64           `json:"tolerance" example:"0.000001"`
+	MaxIter   int               `json:"max_iter" example:"100"`
+	Algorithm string            `json:"algorithm" example:"newton"`
+	Precision int               `json:"precision,omitempty" example:"0"`
+	Criterion string            `json:"criterion,omitempty" example:"step"`
+	Timeout   string            `json:"timeout,omitempty" example:"500ms"` // Go duration
+	Extra     map[string]string `json:"extra,omitempty"`                   // Passthrough data, echoed in the response
+}
+
+type SolveResponse struct {
+	Id        int           `json:"id"`
+	Job       *SolveRequest `json:"job,omitempty"` // Echo of the job, when solved in a batch
+	Solutions []Solution    `json:"solutions"`
+	Error     string        `json:"error,omitempty"` // Set when the job itself was rejected
+}
+
+type Solution struct {
+	X      float64 `json:"x" example:"2.0"`
+	Steps  int     `json:"steps" example:"5"`
+	Method string  `json:"method,omitempty" example:"newton"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Job converts a request into a job. The algorithm is returned apart
+// since it is not part of the job itself.
+func (req SolveRequest) Job() (Job, error) {
+	job := Job{
+		Id: req.Id,
+		N:  req.N, M: req.M, K: req.K,
+		A: req.A, B: req.B,
+		Tol: req.Tolerance, MaxIter: req.MaxIter,
+		Precision: req.Precision, Criterion: req.Criterion,
+	}
+	if req.Timeout != "" {
+		timeout, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			return job, fmt.Errorf("invalid timeout %q: %w", req.Timeout, err)
+		}
+		job.Timeout = timeout
+	}
+	return job, nil
+}
+
+// NewSolveRequest is the inverse of SolveRequest.Job.
+func NewSolveRequest(job Job, algorithm string) SolveRequest {
+	req := SolveRequest{
+		Id: job.Id,
+		N:  job.N, M: job.M, K: job.K,
+		A: job.A, B: job.B,
+		Tolerance: job.Tol, MaxIter: job.MaxIter,
+		Algorithm: algorithm,
+		Precision: job.Precision, Criterion: job.Criterion,
+	}
+	if job.Timeout > 0 {
+		req.Timeout = job.Timeout.String()
+	}
+	return req
+}
+
+// NewSolveResponse gathers the results of a job.
+func NewSolveResponse(id int, results []Result) SolveResponse {
+	resp := SolveResponse{Id: id, Solutions: make([]Solution, len(results))}
+	for i, result := range results {
+		resp.Solutions[i] = Solution{X: result.X, Steps: result.Steps, Method: result.Method}
+		if result.Err != nil {
+			resp.Solutions[i].Error = result.Err.Error()
+		}
+	}
+	return resp
+}
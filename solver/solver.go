@@ -1,49 +1,72 @@
 package solver
 
 import (
-	"log"
+	"log/slog"
+	"slices"
+	"time"
 )
 
 // Equation to solve: x^n = K * m^x
 // equivalently: f(x) = n * ln(x) - ln(K) - x * ln(m) = 0
 // f'(x) = n/x - ln(m)
 
-func (job Job) Solve(method string, logger *log.Logger) []Result {
+const (
+	METHOD_NEWTON    = "newton"
+	METHOD_BISECTION = "bisection"
+	METHOD_AUTO      = "auto"
+	METHOD_ANALYTIC  = "analytic" // Closed-form solution of an edge case, reported in results only
+)
+
+// Methods lists the algorithms accepted by Solve.
+var Methods = []string{METHOD_NEWTON, METHOD_BISECTION, METHOD_AUTO}
+
+// IsMethod reports whether name is one of the accepted algorithms.
+func IsMethod(name string) bool {
+	return slices.Contains(Methods, name)
+}
+
+// Solve finds the roots of the job with method. Failures of the method
+// are logged at debug level, see SetLogHandler.
+func (job Job) Solve(method string) []Result {
 	var solutions []Result
 
 	// Handle edge cases first
 	if done, solution := job.handleEdgeCases(); done {
 		if solution != -1.0 {
-			return append(solutions, Result{Id: job.Id, X: solution, Steps: 0, Err: nil})
+			return append(solutions, Result{Id: job.Id, X: solution, Steps: 0, Method: METHOD_ANALYTIC, Err: nil})
 		}
 	}
 
+	if job.Timeout > 0 {
+		job.deadline = time.Now().Add(job.Timeout)
+	}
+
 	switch method {
-	case "newton":
+	case METHOD_NEWTON:
 		for _, x0 := range job.GetInitValues() {
 			result := NewtonSolve(job, x0)
 			if result.Err != nil {
-				logger.Println("Error:", result.Err)
-				solutions = append(solutions, Result{Id: job.Id, X: -1.0, Steps: 0, Err: result.Err})
+				logJob(slog.LevelDebug, job, method, "Method failed", "error", result.Err)
+				solutions = append(solutions, Result{Id: job.Id, X: -1.0, Steps: 0, Method: result.Method, Err: result.Err})
 			} else {
 				// fmt.Printf("Found solution x = %.6f in %d steps\n", result.X, result.Steps)
 				solutions = append(solutions, result)
 			}
 		}
-	case "bisection":
+	case METHOD_BISECTION:
 		// For bisection, we need an interval [lower, upper]
 		// Here, we use x0 as the midpoint and create a small interval around it
 		for _, interval := range getIntervals(job) {
 			result := BisectionSolve(job, interval[0], interval[1])
 			if result.Err != nil {
-				logger.Println("Error:", result.Err)
-				solutions = append(solutions, Result{Id: job.Id, X: -1.0, Steps: 0, Err: result.Err})
+				logJob(slog.LevelDebug, job, method, "Method failed", "error", result.Err)
+				solutions = append(solutions, Result{Id: job.Id, X: -1.0, Steps: 0, Method: result.Method, Err: result.Err})
 			} else {
 				// fmt.Printf("Found solution x = %.6f in %d steps\n", result.X, result.Steps)
 				solutions = append(solutions, result)
 			}
 		}
-	case "auto":
+	case METHOD_AUTO:
 		// First try Newton-Raphson with multiple initial guesses
 		for _, x0 := range job.GetInitValues() {
 			result := NewtonSolve(job, x0)
@@ -53,6 +76,7 @@ func (job Job) Solve(method string, logger *log.Logger) []Result {
 		}
 		// If no solutions found, fall back to Bisection method
 		if len(solutions) == 0 {
+			logJob(slog.LevelDebug, job, method, "Newton found no root, falling back to bisection")
 			for _, interval := range getIntervals(job) {
 				result := BisectionSolve(job, interval[0], interval[1])
 				if result.Err == nil {
@@ -61,11 +85,8 @@ func (job Job) Solve(method string, logger *log.Logger) []Result {
 			}
 		}
 	default:
-		logger.Println("Unknown method:", method)
+		logJob(slog.LevelError, job, method, "Unknown method")
 	}
 
 	return solutions
 }
-
-
-
@@ -0,0 +1,63 @@
+package solver
+
+import (
+	"errors"
+	"iter"
+	"log/slog"
+)
+
+const DEFAULT_ERROR_SOLUTION = -1.0
+
+var (
+	ErrNoSolutionsExist = errors.New("no solutions exist for the given parameters")
+	ErrNoSolutionsFound = errors.New("no solutions found")
+)
+
+// Process validates and solves a single job.
+// It always returns at least one result: failures are reported as a result
+// carrying DEFAULT_ERROR_SOLUTION and the error, so every job leaves a trace.
+func (job Job) Process(method string) []Result {
+	return job.process(method, Job.Solve)
+}
+
+// process is Process, solving the job with solve.
+func (job Job) process(method string, solve func(Job, string) []Result) []Result {
+	if err := job.Validate(); err != nil {
+		logJob(slog.LevelWarn, job, method, "Invalid job parameters", "error", err)
+		return []Result{{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: err}}
+	}
+	if !job.SolutionsExist() {
+		return []Result{{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: ErrNoSolutionsExist}}
+	}
+	solutions := solve(job, method)
+	if len(solutions) == 0 {
+		return []Result{{Id: job.Id, X: DEFAULT_ERROR_SOLUTION, Steps: 0, Err: ErrNoSolutionsFound}}
+	}
+	return solutions
+}
+
+// SolveJobs lazily solves the jobs yielded by jobs, one at a time,
+// and yields each job together with its results.
+// Nothing is buffered, so memory stays bounded whatever the number of jobs.
+func SolveJobs(jobs iter.Seq[Job], method string) iter.Seq2[Job, []Result] {
+	return func(yield func(Job, []Result) bool) {
+		for job := range jobs {
+			if !yield(job, job.Process(method)) {
+				return
+			}
+		}
+	}
+}
+
+// Results flattens a SolveJobs sequence into a sequence of single results.
+func Results(solved iter.Seq2[Job, []Result]) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		for _, results := range solved {
+			for _, result := range results {
+				if !yield(result) {
+					return
+				}
+			}
+		}
+	}
+}
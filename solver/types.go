@@ -1,23 +1,36 @@
 package solver
 
+import "time"
+
 type Job struct {
 	Id      int
 	N, M, K float64
 	A, B    float64
 	Tol     float64
 	MaxIter int
+
+	// Optional stopping controls, zero values keep the method defaults
+	Precision int           // Significant digits required on x, makes the step test relative
+	Criterion string        // One of the CRITERION_* values
+	Timeout   time.Duration // Wall-clock limit for the whole job
+
+	deadline time.Time // Set by Solve from Timeout
 }
 
 type Result struct {
-	Id    int
-	X     float64
-	Steps int
-	Err   error
+	Id     int
+	X      float64
+	Steps  int
+	Method string // Algorithm that produced X, METHOD_ANALYTIC for closed forms
+	Err    error
 }
 
+// Batch describes a streamed run over a jobs file.
+// Jobs and results are never held in memory, only counted.
 type Batch struct {
-	InFile  string
-	OutFile string
-	Jobs    []Job
-	Results []Result
+	InFile     string
+	OutFile    string
+	NbJobs     int
+	NbResults  int
+	NbRejected int // Input rows that could not be turned into jobs
 }
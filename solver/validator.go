@@ -27,6 +27,15 @@ func (job Job) Validate() error {
 	if job.MaxIter <= 0 {
 		return errors.New("maxIter must be positive")
 	}
+	if job.Precision < 0 {
+		return errors.New("precision must be positive or zero")
+	}
+	if !IsCriterion(job.Criterion) {
+		return errors.New("unknown stopping criterion " + job.Criterion)
+	}
+	if job.Timeout < 0 {
+		return errors.New("timeout must be positive or zero")
+	}
 	return nil
 }
 